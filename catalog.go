@@ -0,0 +1,140 @@
+package hiradio
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// Catalog represents a snapshot of the channel lineup with rankings.
+type Catalog struct {
+	Time     time.Time        `json:"time"`
+	Channels []CatalogChannel `json:"channels"`
+}
+
+// CatalogChannel represents a channel in the catalog. Ranking is zero if the
+// channel is not ranked.
+type CatalogChannel struct {
+	Channel
+	Ranking int `json:"ranking"`
+}
+
+// GetCatalog fetches channels and rankings as a catalog.
+func (c *Client) GetCatalog() (*Catalog, error) {
+	type lrs struct {
+		rankings []Ranking
+		err      error
+	}
+	rankingsCh := make(chan lrs, 1)
+	go func() {
+		result, err := c.ListRankings()
+		rankingsCh <- lrs{result, err}
+	}()
+
+	channels, err := c.ListChannels()
+	if err != nil {
+		return nil, err
+	}
+	result := <-rankingsCh
+	if result.err != nil {
+		return nil, result.err
+	}
+	return NewCatalog(time.Now(), channels, result.rankings), nil
+}
+
+// NewCatalog returns a catalog of channels with rankings, ordered by ID.
+func NewCatalog(t time.Time, channels []Channel, rankings []Ranking) *Catalog {
+	ranks := make(map[int]int, len(rankings))
+	for _, r := range rankings {
+		ranks[r.ID] = r.Value
+	}
+
+	cat := &Catalog{Time: t, Channels: make([]CatalogChannel, 0, len(channels))}
+	seen := make(map[int]bool, len(channels))
+	for _, ch := range channels {
+		if seen[ch.ID] {
+			continue
+		}
+		seen[ch.ID] = true
+		cat.Channels = append(cat.Channels, CatalogChannel{ch, ranks[ch.ID]})
+	}
+	sort.Sort(byChannelID(cat.Channels))
+	return cat
+}
+
+type byChannelID []CatalogChannel
+
+func (s byChannelID) Len() int           { return len(s) }
+func (s byChannelID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byChannelID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+// Save writes the catalog to w in JSON format.
+func (cat *Catalog) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(cat)
+}
+
+// LoadCatalog reads a catalog written by Save from r.
+func LoadCatalog(r io.Reader) (*Catalog, error) {
+	cat := new(Catalog)
+	if err := json.NewDecoder(r).Decode(cat); err != nil {
+		return nil, err
+	}
+	return cat, nil
+}
+
+// CatalogDiff represents the changes between two catalogs.
+type CatalogDiff struct {
+	Added   []Channel       `json:"added"`
+	Removed []Channel       `json:"removed"`
+	Renamed []ChannelChange `json:"renamed"`
+	Retyped []ChannelChange `json:"retyped"`
+}
+
+// ChannelChange represents a channel changed between two catalogs.
+type ChannelChange struct {
+	Old Channel `json:"old"`
+	New Channel `json:"new"`
+}
+
+// Empty reports whether there is no change.
+func (d *CatalogDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 &&
+		len(d.Renamed) == 0 && len(d.Retyped) == 0
+}
+
+// Diff returns the changes from cat to newer. Channels are matched by ID.
+func (cat *Catalog) Diff(newer *Catalog) *CatalogDiff {
+	old := make(map[int]Channel, len(cat.Channels))
+	for _, ch := range cat.Channels {
+		old[ch.ID] = ch.Channel
+	}
+	current := make(map[int]bool, len(newer.Channels))
+
+	d := new(CatalogDiff)
+	for _, ch := range newer.Channels {
+		current[ch.ID] = true
+		o, found := old[ch.ID]
+		if !found {
+			d.Added = append(d.Added, ch.Channel)
+			continue
+		}
+		if o.Title != ch.Title {
+			d.Renamed = append(d.Renamed, ChannelChange{o, ch.Channel})
+		}
+		if o.Type != ch.Type {
+			d.Retyped = append(d.Retyped, ChannelChange{o, ch.Channel})
+		}
+	}
+	for _, ch := range cat.Channels {
+		if !current[ch.ID] {
+			d.Removed = append(d.Removed, ch.Channel)
+		}
+	}
+	return d
+}
+
+// GetCatalog fetches channels and rankings as a catalog.
+func GetCatalog() (*Catalog, error) {
+	return DefaultClient.GetCatalog()
+}
@@ -0,0 +1,118 @@
+package hiradio
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetCatalog(t *testing.T) {
+	setup()
+	defer teardown()
+	channels := `{
+    "pageNo": 1,
+    "pageSize": 1,
+    "list":[
+        {
+            "channel_id": "222",
+            "channel_image": "14abcde694d00000b2fc.jpg",
+            "channel_title": "HitFm聯播網 Taipei 北部",
+            "isChannel": true,
+            "program_name": "週日 HIT DJ",
+            "radio_type": "1"
+        },
+        {
+            "channel_id": "109",
+            "channel_image": "14a7b212625000003d2d.jpg",
+            "channel_title": "大千電台",
+            "isChannel": true,
+            "program_name": "Super Live Show",
+            "radio_type": "2"
+        }
+    ]
+}`
+	rankings := `{
+    "list": [
+        {
+            "channel_id": "222",
+            "channel_rank": "1"
+        }
+    ]
+}`
+	mux.HandleFunc("/radio/channelList.do", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(channels))
+	})
+	mux.HandleFunc("/radio/getRanking.do", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(rankings))
+	})
+
+	got, err := client.GetCatalog()
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := []CatalogChannel{
+		{Channel{109, "大千電台", "14a7b212625000003d2d.jpg", 2, "Super Live Show"}, 0},
+		{Channel{222, "HitFm聯播網 Taipei 北部", "14abcde694d00000b2fc.jpg", 1, "週日 HIT DJ"}, 1},
+	}
+	if !reflect.DeepEqual(got.Channels, want) {
+		t.Fatalf("got %+v, want %+v", got.Channels, want)
+	}
+}
+
+func TestSaveAndLoadCatalog(t *testing.T) {
+	want := NewCatalog(time.Date(2015, 3, 1, 0, 0, 0, 0, time.UTC),
+		[]Channel{{222, "HitFm聯播網 Taipei 北部", "14abcde694d00000b2fc.jpg", 1, "週日 HIT DJ"}},
+		[]Ranking{{222, 1}})
+
+	var buf bytes.Buffer
+	if err := want.Save(&buf); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	got, err := LoadCatalog(&buf)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCatalogDiff(t *testing.T) {
+	old := NewCatalog(time.Time{}, []Channel{
+		{109, "大千電台", "", 2, ""},
+		{156, "KISS RADIO 大眾廣播電台", "", 1, ""},
+		{222, "HitFm聯播網 Taipei 北部", "", 1, ""},
+		{300, "大漢之音", "", 6, ""},
+	}, nil)
+	newer := NewCatalog(time.Time{}, []Channel{
+		{109, "大千電台", "", 4, ""},
+		{222, "HitFm聯播網 北部", "", 1, ""},
+		{300, "大漢之音 FM97.1", "", 2, ""},
+		{308, "KISS RADIO 網路音樂台", "", 1, ""},
+	}, nil)
+
+	got := old.Diff(newer)
+	want := &CatalogDiff{
+		Added:   []Channel{{308, "KISS RADIO 網路音樂台", "", 1, ""}},
+		Removed: []Channel{{156, "KISS RADIO 大眾廣播電台", "", 1, ""}},
+		Renamed: []ChannelChange{
+			{Channel{222, "HitFm聯播網 Taipei 北部", "", 1, ""}, Channel{222, "HitFm聯播網 北部", "", 1, ""}},
+			{Channel{300, "大漢之音", "", 6, ""}, Channel{300, "大漢之音 FM97.1", "", 2, ""}},
+		},
+		Retyped: []ChannelChange{
+			{Channel{109, "大千電台", "", 2, ""}, Channel{109, "大千電台", "", 4, ""}},
+			{Channel{300, "大漢之音", "", 6, ""}, Channel{300, "大漢之音 FM97.1", "", 2, ""}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got.Empty() {
+		t.Fatal("diff should not be empty")
+	}
+	if !newer.Diff(newer).Empty() {
+		t.Fatal("diff of same catalog should be empty")
+	}
+}
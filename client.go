@@ -4,6 +4,7 @@ package hiradio
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"time"
@@ -13,6 +14,9 @@ const (
 	libraryVersion   = "0.1"
 	defaultEndpoint  = "http://hichannel.hinet.net/radio/"
 	defaultUserAgent = "hiradio/" + libraryVersion
+
+	defaultMaxRetries    = 3
+	defaultMaxRetryDelay = 1 * time.Minute
 )
 
 // A Client manages communicating with Hichannel API.
@@ -24,18 +28,62 @@ type Client struct {
 
 	// User agent used when communicating with the Hichannel API.
 	UserAgent string
+
+	// Limiter limits the rate of API requests if not nil.
+	Limiter *RateLimiter
+
+	// MaxRetries is the maximum number of retries of a request responded
+	// with 429 or 503 and a Retry-After header. The request is not retried
+	// if the delay is longer than MaxRetryDelay.
+	MaxRetries    int
+	MaxRetryDelay time.Duration
+
+	middlewares []Middleware
+}
+
+// Doer sends an HTTP request and returns the response, *http.Client is a
+// Doer.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc is an adapter to allow the use of a function as a Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req).
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps the sending of API requests, e.g. to log, trace or mutate
+// requests and responses. It may be called concurrently.
+type Middleware func(next Doer) Doer
+
+// Use appends middlewares to the chain of API requests. The first middleware
+// is the outermost one, which sees the request first and the response last.
+func (c *Client) Use(middlewares ...Middleware) {
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+// doer returns the chain of middlewares ending with the HTTP client.
+func (c *Client) doer() Doer {
+	var d Doer = c.client
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		d = c.middlewares[i](d)
+	}
+	return d
 }
 
 // Channel represents a Hichannel channel.
 //
 // Source: http://hichannel.hinet.net/radio/channelList.do?radioType=&freqType=&freq=&area=&pN=%d
 type Channel struct {
-	ID    int
-	Title string
-	Image string
-	Type  RadioType
+	ID    int       `json:"channel_id"`
+	Title string    `json:"channel_title"`
+	Image string    `json:"channel_image"`
+	Type  RadioType `json:"radio_type"`
 
-	ProgramName string
+	ProgramName string `json:"program_name"`
 }
 
 // RadioType represents a Hichannel radio type.
@@ -133,7 +181,7 @@ func (c *Client) fetchChannelList(page int) (*channelList, error) {
 
 func (c *Client) fetchObject(req *http.Request, v interface{}) error {
 	req.Header.Set("User-Agent", c.UserAgent)
-	res, err := c.client.Do(req)
+	res, err := c.send(req)
 	if err != nil {
 		return err
 	}
@@ -147,6 +195,36 @@ func (c *Client) fetchObject(req *http.Request, v interface{}) error {
 	return dec.Decode(v)
 }
 
+// send sends the request when the limiter allows. Responses of 429 or 503
+// with Retry-After are retried after the delay, which also holds other
+// requests sharing the limiter.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	for retries := 0; ; retries++ {
+		if c.Limiter != nil {
+			c.Limiter.Wait()
+		}
+		res, err := c.doer().Do(req)
+		if err != nil {
+			return nil, err
+		}
+		delay, found := retryAfter(res, time.Now())
+		if !found {
+			return res, nil
+		}
+		if c.Limiter != nil {
+			c.Limiter.pause(time.Now().Add(delay))
+		}
+		if retries >= c.MaxRetries || delay > c.MaxRetryDelay {
+			return res, nil
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		if c.Limiter == nil {
+			time.Sleep(delay)
+		}
+	}
+}
+
 type responseError struct {
 	Response *http.Response
 	Message  string
@@ -323,6 +401,8 @@ func NewClient(client *http.Client) *Client {
 	c.client = client
 	c.Endpoint = defaultEndpoint
 	c.UserAgent = defaultUserAgent
+	c.MaxRetries = defaultMaxRetries
+	c.MaxRetryDelay = defaultMaxRetryDelay
 	return c
 }
 
@@ -333,8 +413,10 @@ var DefaultClient = &Client{
 	client: &http.Client{
 		Timeout: 1 * time.Minute,
 	},
-	Endpoint:  defaultEndpoint,
-	UserAgent: defaultUserAgent,
+	Endpoint:      defaultEndpoint,
+	UserAgent:     defaultUserAgent,
+	MaxRetries:    defaultMaxRetries,
+	MaxRetryDelay: defaultMaxRetryDelay,
 }
 
 // ListChannels list all channels.
@@ -258,6 +258,76 @@ func TestListRankings(t *testing.T) {
 	}
 }
 
+func TestUse(t *testing.T) {
+	setup()
+	defer teardown()
+	mux.HandleFunc("/radio/getRanking.do", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Request-Id", req.Header.Get("X-Request-Id"))
+		w.Write([]byte(`{"list":[]}`))
+	})
+	var got []string
+	trace := func(name string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				got = append(got, name+" "+req.Header.Get("X-Request-Id"))
+				resp, err := next.Do(req)
+				if err == nil {
+					got = append(got, name+" "+resp.Header.Get("X-Request-Id"))
+				}
+				return resp, err
+			})
+		}
+	}
+	inject := func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Request-Id", "1")
+			return next.Do(req)
+		})
+	}
+	client.Use(trace("outer"), inject)
+	client.Use(trace("inner"))
+
+	if _, err := client.ListRankings(); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := []string{"outer ", "inner 1", "inner 1", "outer 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	setup()
+	defer teardown()
+	requests := 0
+	mux.HandleFunc("/radio/getRanking.do", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if requests < 3 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"list":[]}`))
+	})
+	client.Limiter = NewRateLimiter(100, 1)
+
+	if _, err := client.ListRankings(); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if requests != 3 {
+		t.Fatalf("got %d requests, want 3", requests)
+	}
+
+	requests = 0
+	client.MaxRetries = 1
+	if _, err := client.ListRankings(); err == nil {
+		t.Fatalf("expected err")
+	}
+	if requests != 2 {
+		t.Fatalf("got %d requests, want 2", requests)
+	}
+}
+
 func TestIntegrationListChannels(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping intergration test")
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// alarmPollInterval is the maximum interval of checking the wall clock while
+// waiting for the alarm.
+const alarmPollInterval = 1 * time.Minute
+
+func alarmCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("alarm", flag.ExitOnError)
+	opts := newPlayOptions(fs)
+	weekdays := fs.Bool("weekdays", false, "Start only on weekdays (Monday to Friday)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio alarm [options] HH:MM [ChannelID]
+
+Play radio on player at the given time
+
+The options are:`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nThe player presets are:\n%s\n", playerUsage(opts.presets))
+		os.Exit(1)
+	}
+
+	// parse arguments
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return
+	}
+	hour, min, err := parseClock(fs.Arg(0))
+	if err != nil {
+		Fatalf("Failed to parse time: %s", err)
+	}
+	channelID, err := getChannelID(fs.Args()[1:])
+	if err != nil {
+		if err == errNotFound {
+			fs.Usage()
+			return
+		}
+		Fatalf("Failed to parse ChannelID: %s", fs.Arg(1))
+	}
+
+	// wait for the alarm
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	at := nextAlarm(time.Now(), hour, min, *weekdays)
+	fmt.Printf("Alarm at %s, press ctrl-c to cancel\n", at.Format("2006-01-02 15:04 Mon"))
+	if !waitUntil(at, quit) {
+		return
+	}
+
+	play(channelID, opts, quit)
+}
+
+// waitUntil waits until the wall clock reaches at, and reports whether it is
+// reached before quit is received. Timers stop while the system is suspended,
+// so the wall clock is checked every alarmPollInterval.
+func waitUntil(at time.Time, quit <-chan os.Signal) bool {
+	for {
+		d := at.Sub(time.Now())
+		if d <= 0 {
+			return true
+		}
+		if d > alarmPollInterval {
+			d = alarmPollInterval
+		}
+		select {
+		case <-time.After(d):
+		case <-quit:
+			return false
+		}
+	}
+}
+
+// parseClock parses s in format HH:MM.
+func parseClock(s string) (hour, min int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// nextAlarm returns the next time at hour:min after now. Saturday and Sunday
+// are skipped if weekdays is true.
+func nextAlarm(now time.Time, hour, min int, weekdays bool) time.Time {
+	at := time.Date(now.Year(), now.Month(), now.Day(), hour, min, 0, 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	for weekdays && (at.Weekday() == time.Saturday || at.Weekday() == time.Sunday) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at
+}
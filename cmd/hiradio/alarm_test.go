@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNextAlarm(t *testing.T) {
+	// 2015-03-06 is Friday
+	friday := time.Date(2015, 3, 6, 8, 30, 0, 0, time.Local)
+	tests := []struct {
+		now      time.Time
+		hour     int
+		min      int
+		weekdays bool
+		want     time.Time
+	}{
+		{friday, 9, 0, false, time.Date(2015, 3, 6, 9, 0, 0, 0, time.Local)},
+		{friday, 7, 0, false, time.Date(2015, 3, 7, 7, 0, 0, 0, time.Local)},
+		{friday, 7, 0, true, time.Date(2015, 3, 9, 7, 0, 0, 0, time.Local)},
+		{friday, 8, 30, true, time.Date(2015, 3, 9, 8, 30, 0, 0, time.Local)},
+	}
+	for _, test := range tests {
+		got := nextAlarm(test.now, test.hour, test.min, test.weekdays)
+		if !got.Equal(test.want) {
+			t.Fatalf("nextAlarm(%s, %d, %d, %v): got %s, want %s",
+				test.now, test.hour, test.min, test.weekdays, got, test.want)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	hour, min, err := parseClock("07:05")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if hour != 7 || min != 5 {
+		t.Fatalf("got %d:%d, want 7:5", hour, min)
+	}
+
+	if _, _, err := parseClock("25:00"); err == nil {
+		t.Fatal("expected error on invalid time")
+	}
+}
+
+func TestWaitUntil(t *testing.T) {
+	quit := make(chan os.Signal, 1)
+	if !waitUntil(time.Now().Add(-time.Minute), quit) {
+		t.Fatalf("got false, want true for a past time")
+	}
+	quit <- os.Interrupt
+	if waitUntil(time.Now().Add(time.Hour), quit) {
+		t.Fatalf("got true, want false after quit")
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/archive"
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+)
+
+const (
+	archiveDir = "archive"
+	// pruneInterval is the interval of removing expired recordings.
+	pruneInterval = 1 * time.Hour
+	// maxClockDrift is the maximum difference between the recorded and
+	// arrival time of a segment before the recorded time is reset.
+	maxClockDrift = 1 * time.Minute
+)
+
+func archiveCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	dir := fs.String("dir", cfg.GetString(archiveDirKey, ""), "Directory of the archive (archive under the application data directory if empty)")
+	port := fs.Int("port", cfg.GetInt(archivePortKey, defaultArchivePort), "Port for the archive server")
+	retention := fs.Duration("retention", cfg.GetDuration(archiveRetentionKey, defaultArchiveRetention), "Retention of recordings")
+	refresh := fs.Duration("refresh", 30*time.Minute, "Interval for indexing the program lists")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio archive [options] [ChannelID...]
+
+Record channels continuously and serve past programs for listening again.
+The channels in the archiveChannels setting are recorded if no ChannelID is
+given.
+
+The server provides:
+    /archive/                          ChannelIDs in the archive
+    /archive/ID/programs?date=DATE     Archived programs on the date (YYYY-MM-DD)
+    /archive/ID/START.m3u8             HLS playlist of the program started at START (Unix time)
+    /archive/ID/START.ts               Download the program
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+
+	channelIDs := cfg.GetIntSlice(archiveChannelsKey, nil)
+	if fs.NArg() > 0 {
+		channelIDs = nil
+		for _, arg := range fs.Args() {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				Fatalf("Failed to parse ChannelID: %s", arg)
+			}
+			channelIDs = append(channelIDs, id)
+		}
+	}
+	if len(channelIDs) == 0 {
+		Fatal("No channels to archive, specify ChannelIDs or the archiveChannels setting")
+	}
+
+	if *dir == "" {
+		path, err := configPath(archiveDir)
+		if err != nil {
+			Fatalf("Failed to open archive: %s", err)
+		}
+		*dir = path
+	}
+	a, err := archive.Open(*dir)
+	if err != nil {
+		Fatalf("Failed to open archive: %s", err)
+	}
+
+	// record channels
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, id := range channelIDs {
+		c, err := a.Channel(id)
+		if err != nil {
+			Fatalf("Failed to open archive of channel %d: %s", id, err)
+		}
+		wg.Add(2)
+		go func(id int) {
+			defer wg.Done()
+			archiveStream(id, c, stop)
+		}(id)
+		go func(id int) {
+			defer wg.Done()
+			indexPrograms(id, c, *refresh, stop)
+		}(id)
+	}
+	go pruneArchive(a, *retention, stop)
+
+	// serve recordings
+	go func() {
+		addr := ":" + strconv.Itoa(*port)
+		if err := http.ListenAndServe(addr, &archiveServer{a}); err != nil {
+			Fatalf("Failed to start archive server: %s", err)
+		}
+	}()
+	fmt.Printf("Recording %v into %s, serving at http://localhost:%d/archive/\n", channelIDs, *dir, *port)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	close(stop)
+	wg.Wait()
+}
+
+// archiveStream records the stream of the channel into the archive until stop
+// is closed.
+func archiveStream(channelID int, c *archive.Channel, stop <-chan struct{}) {
+	var (
+		started  bool
+		sequence int
+		end      time.Time
+	)
+	recordStream(channelID, newStream(channelID), stop, func(seg hls.Segment, data []byte) error {
+		// a segment is recorded before it arrives
+		start := time.Now().Add(-seg.Duration)
+		if started && seg.Sequence == sequence+1 {
+			if d := start.Sub(end); -maxClockDrift < d && d < maxClockDrift {
+				start = end
+			}
+		}
+		if err := c.Add(start, seg.Duration, path.Ext(seg.URI), data); err != nil {
+			return err
+		}
+		started, sequence, end = true, seg.Sequence, start.Add(seg.Duration)
+		return nil
+	})
+}
+
+// indexPrograms indexes the program list of the channel every interval until
+// stop is closed.
+func indexPrograms(channelID int, c *archive.Channel, interval time.Duration, stop <-chan struct{}) {
+	for {
+		info, err := client.GetChannelInfo(channelID)
+		if err != nil {
+			Warnf("Failed to fetch program list of %d: %s", channelID, err)
+		} else if err := c.AddPrograms(archive.ResolvePrograms(time.Now(), info.List)); err != nil {
+			Warnf("Failed to index programs of %d: %s", channelID, err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pruneArchive removes the recordings older than retention periodically.
+func pruneArchive(a *archive.Archive, retention time.Duration, stop <-chan struct{}) {
+	for {
+		ids, err := a.ChannelIDs()
+		if err != nil {
+			Warnf("Failed to prune archive: %s", err)
+		}
+		for _, id := range ids {
+			c, err := a.Channel(id)
+			if err == nil {
+				err = c.Prune(time.Now().Add(-retention))
+			}
+			if err != nil {
+				Warnf("Failed to prune archive of channel %d: %s", id, err)
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(pruneInterval):
+		}
+	}
+}
+
+// archivedProgram is a program in the archive with its URLs.
+type archivedProgram struct {
+	archive.Program
+	Playlist string `json:"playlist"`
+	Download string `json:"download"`
+}
+
+type archiveServer struct {
+	archive *archive.Archive
+}
+
+var archiveRouteRE = regexp.MustCompile(`^/archive/(\d+)/(programs|segments/([\w.-]+)|(\d+)\.(\w+))$`)
+
+func (s *archiveServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/archive/" {
+		ids, err := s.archive.ChannelIDs()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(rw, ids)
+		return
+	}
+
+	matched := archiveRouteRE.FindStringSubmatch(req.URL.Path)
+	if matched == nil {
+		http.NotFound(rw, req)
+		return
+	}
+	channelID, _ := strconv.Atoi(matched[1])
+	if !s.archive.Has(channelID) {
+		http.NotFound(rw, req)
+		return
+	}
+	c, err := s.archive.Channel(channelID)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case matched[2] == "programs":
+		day := time.Now()
+		if date := req.FormValue("date"); date != "" {
+			if day, err = time.ParseInLocation("2006-01-02", date, time.Local); err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+		list := []archivedProgram{}
+		for _, p := range c.Programs(from, from.AddDate(0, 0, 1)) {
+			segments := c.Segments(p.Start, p.Stop)
+			if len(segments) == 0 {
+				continue
+			}
+			prefix := fmt.Sprintf("/archive/%d/%d", channelID, p.Start.Unix())
+			list = append(list, archivedProgram{p, prefix + ".m3u8", prefix + path.Ext(segments[0].Name)})
+		}
+		writeJSON(rw, list)
+
+	case matched[3] != "":
+		name := matched[3]
+		rw.Header().Set("Content-Type", segmentType(name))
+		http.ServeFile(rw, req, c.Path(archive.Segment{Name: name}))
+
+	default:
+		start, _ := strconv.ParseInt(matched[4], 10, 64)
+		p, found := c.Program(time.Unix(start, 0))
+		if !found {
+			http.NotFound(rw, req)
+			return
+		}
+		segments := c.Segments(p.Start, p.Stop)
+		if len(segments) == 0 {
+			http.NotFound(rw, req)
+			return
+		}
+		if matched[5] == "m3u8" {
+			rw.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			programPlaylist(p, segments).Encode(rw)
+			return
+		}
+		downloadProgram(rw, channelID, p, c, segments)
+	}
+}
+
+// programPlaylist returns the VOD playlist of the program.
+func programPlaylist(p archive.Program, segments []archive.Segment) *hls.Playlist {
+	pl := &hls.Playlist{Type: "VOD", EndList: true}
+	for i, s := range segments {
+		if s.Duration > pl.TargetDuration {
+			pl.TargetDuration = s.Duration
+		}
+		pl.Segments = append(pl.Segments, hls.Segment{
+			URI:           "segments/" + s.Name,
+			Duration:      s.Duration,
+			Sequence:      i,
+			Title:         p.Name,
+			Discontinuity: i > 0 && !segments[i-1].End().Equal(s.Start),
+		})
+	}
+	return pl
+}
+
+// downloadProgram writes the segments of the program as a file.
+func downloadProgram(rw http.ResponseWriter, channelID int, p archive.Program, c *archive.Channel, segments []archive.Segment) {
+	ext := path.Ext(segments[0].Name)
+	name := fmt.Sprintf("%d-%s%s", channelID, p.Start.Format("20060102-1504"), ext)
+	rw.Header().Set("Content-Type", segmentType(name))
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	for _, s := range segments {
+		f, err := os.Open(c.Path(s))
+		if err != nil {
+			Warnf("Failed to read recording: %s", err)
+			continue
+		}
+		_, err = io.Copy(rw, f)
+		f.Close()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(v); err != nil {
+		Warnf("Failed to write response: %s", err)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/capture"
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+)
+
+func captureCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	output := fs.String("o", "", "Output file (ChannelID-YYYYMMDD-HHMMSS with the extension of the stream if empty)")
+	duration := fs.Duration("duration", 0, "Stop capturing after the duration, 0 means until ctrl-c")
+	resume := fs.Bool("resume", false, "Append to the output file from the last segment in its report")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio capture [options] ChannelID
+
+Capture the live stream of the channel into a file, surviving network errors
+and expired playlists. Missed segments are logged as gaps in the report of
+recording completeness, saved as FILE`+capture.ReportExt+`
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return
+	}
+	channelID, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		Fatalf("Failed to parse ChannelID: %s", fs.Arg(0))
+	}
+
+	s := newStream(channelID)
+	report := &capture.Report{ChannelID: channelID}
+	var f *os.File
+	if *resume {
+		if *output == "" {
+			Fatal("Resuming requires -o")
+		}
+		if report, err = capture.Load(*output); err != nil {
+			Fatalf("Failed to load report: %s", err)
+		}
+		if f, err = os.OpenFile(*output, os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			Fatalf("Failed to open %s: %s", *output, err)
+		}
+		report.File = *output
+		s.Resume(report.LastSequence)
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		var timeout <-chan time.Time
+		if *duration > 0 {
+			timeout = time.After(*duration)
+		}
+		select {
+		case <-quit:
+		case <-timeout:
+		}
+		close(stop)
+	}()
+
+	fmt.Printf("Capturing channel %d, press ctrl-c to exit\n", channelID)
+	recordStream(channelID, s, stop, func(seg hls.Segment, data []byte) error {
+		if f == nil {
+			name := *output
+			if name == "" {
+				name = fmt.Sprintf("%d-%s%s", channelID, time.Now().Format("20060102-150405"), path.Ext(seg.URI))
+			}
+			created, err := os.Create(name)
+			if err != nil {
+				return err
+			}
+			f = created
+			report.File = name
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+
+		if gap := report.Add(seg, time.Now()); gap != nil {
+			if gap.Restarted() {
+				Warnf("Stream of channel %d is restarted, missed about %s at %s", channelID, gap.Duration, formatElapsed(gap.Offset))
+			} else {
+				Warnf("Missed segments %d-%d of channel %d, %s at %s", gap.From, gap.To, channelID, gap.Duration, formatElapsed(gap.Offset))
+			}
+		}
+		if err := capture.Save(report.File, report); err != nil {
+			Warnf("Failed to save report: %s", err)
+		}
+		return nil
+	})
+	if f == nil {
+		Fatal(errNoData)
+	}
+	if err := f.Close(); err != nil {
+		Fatalf("Failed to save %s: %s", report.File, err)
+	}
+	if err := writeTags(report.File, channelID, report.Start, report.Stop); err != nil {
+		Warnf("Failed to tag %s: %s", report.File, err)
+	}
+
+	println()
+	fmt.Printf("Captured %s into %s, %.1f%% complete with %d gaps (%s missed)\n",
+		formatElapsed(report.Recorded), report.File, report.Completeness()*100, len(report.Gaps), report.Missed)
+}
@@ -1,13 +1,340 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/parkghost/hiradio"
 	"github.com/parkghost/hiradio/cmd/internal/config"
 
 	"github.com/surma-dump/goappdata"
 )
 
+const (
+	configFile = "config.json"
+	envPrefix  = "HIRADIO"
+)
+
+// Keys of settings.
+const (
+	channelIDKey = "channelID"
+	playerKey    = "player"
+	playersKey   = "players"
+	proxyPortKey = "proxyPort"
+	volumeCmdKey = "volumeCmd"
+	endpointKey  = "endpoint"
+	timeoutKey   = "timeout"
+	userAgentKey = "userAgent"
+	favoritesKey = "favorites"
+	formatKey    = "format"
+
+	volumeGetCmdKey = "volumeGetCmd"
+
+	requestIntervalKey = "requestInterval"
+	requestBurstKey    = "requestBurst"
+
+	archiveChannelsKey   = "archiveChannels"
+	archiveDirKey        = "archiveDir"
+	archivePortKey       = "archivePort"
+	archiveRetentionKey  = "archiveRetention"
+	recordingsDirKey     = "recordingsDir"
+	recordingsKeepKey    = "recordingsKeep"
+	recordingsMaxAgeKey  = "recordingsMaxAge"
+	recordingsMaxSizeKey = "recordingsMaxSize"
+	metricsRankingsKey   = "metricsRankings"
+)
+
+// Default values of settings.
+const (
+	defaultProxyPort = 1077
+	defaultTimeout   = 1 * time.Minute
+	defaultFormat    = "text"
+	defaultBurst     = 1
+
+	defaultArchivePort      = 1078
+	defaultArchiveRetention = 7 * 24 * time.Hour
+)
+
+type settingKind string
+
+const (
+	kindString    settingKind = "string"
+	kindInt       settingKind = "int"
+	kindDuration  settingKind = "duration"
+	kindIntSlice  settingKind = "[]int"
+	kindStringMap settingKind = "map"
+)
+
+type setting struct {
+	key   string
+	kind  settingKind
+	def   interface{}
+	usage string
+}
+
+// settings is the schema of the configuration file.
+var settings = []setting{
+	{channelIDKey, kindInt, nil, "ChannelID of the last played channel"},
+	{playerKey, kindString, nil, "Player preset, command template or binary path"},
+	{playersKey, kindStringMap, nil, "Custom player presets"},
+	{proxyPortKey, kindInt, defaultProxyPort, "Port for the proxy server"},
+	{volumeCmdKey, kindString, nil, "Command to set the volume for fading"},
+	{volumeGetCmdKey, kindString, nil, "Command printing the current volume, restored after fading"},
+	{endpointKey, kindString, hiradio.DefaultClient.Endpoint, "Endpoint of Hichannel API"},
+	{timeoutKey, kindDuration, defaultTimeout, "Timeout of API requests"},
+	{userAgentKey, kindString, hiradio.DefaultClient.UserAgent, "User agent of API requests"},
+	{requestIntervalKey, kindDuration, nil, "Average interval between API requests, 0 means unlimited"},
+	{requestBurstKey, kindInt, defaultBurst, "Maximum number of API requests sent at once when limited by requestInterval"},
+	{favoritesKey, kindIntSlice, nil, "ChannelIDs of favorite channels"},
+	{formatKey, kindString, defaultFormat, "Output format: text or json"},
+	{archiveChannelsKey, kindIntSlice, nil, "ChannelIDs recorded by the archive server"},
+	{archiveDirKey, kindString, nil, "Directory of the archive (archive under the application data directory if empty)"},
+	{archivePortKey, kindInt, defaultArchivePort, "Port for the archive server"},
+	{archiveRetentionKey, kindDuration, defaultArchiveRetention, "Retention of archived recordings"},
+	{recordingsDirKey, kindString, nil, "Directory of recorded programs (recordings under the application data directory if empty)"},
+	{recordingsKeepKey, kindInt, nil, "Number of the most recent recordings kept for each program"},
+	{recordingsMaxAgeKey, kindDuration, nil, "Maximum age of recordings"},
+	{recordingsMaxSizeKey, kindString, nil, "Maximum total size of recordings, e.g. 10G"},
+	{metricsRankingsKey, kindDuration, nil, "Interval for refreshing channel rankings exposed by the proxy at /metrics, 0 disables them"},
+}
+
+// findSetting returns the setting of key.
+func findSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// value returns the effective value of the setting, including the
+// environment variable override and default value.
+func (s setting) value(c *config.Config) (interface{}, bool) {
+	_, stored := c.Get(s.key)
+	_, overridden := c.LookupEnv(s.key)
+	if !stored && !overridden {
+		return s.def, s.def != nil
+	}
+
+	switch s.kind {
+	case kindInt:
+		return c.GetInt(s.key, 0), true
+	case kindDuration:
+		return c.GetDuration(s.key, 0), true
+	case kindIntSlice:
+		return c.GetIntSlice(s.key, nil), true
+	case kindStringMap:
+		return c.GetStringMap(s.key, nil), true
+	}
+	return c.GetString(s.key, ""), true
+}
+
+// parse parses the value of setting from the command line.
+func (s setting) parse(text string) (interface{}, error) {
+	switch s.kind {
+	case kindInt:
+		return strconv.Atoi(text)
+	case kindDuration:
+		return time.ParseDuration(text)
+	case kindIntSlice:
+		var l []int
+		for _, e := range strings.Split(text, ",") {
+			if e = strings.TrimSpace(e); e == "" {
+				continue
+			}
+			i, err := strconv.Atoi(e)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, i)
+		}
+		return l, nil
+	case kindStringMap:
+		var m map[string]string
+		if err := json.Unmarshal([]byte(text), &m); err != nil {
+			return nil, fmt.Errorf("expected JSON object: %s", err)
+		}
+		return m, nil
+	}
+	return text, nil
+}
+
+var (
+	// cfg is the configuration with environment variable overrides.
+	cfg *config.Config
+	// cfgPath is the path of configuration file, empty if unavailable.
+	cfgPath string
+	// client is the API client configured by settings.
+	client *hiradio.Client
+	// httpClient is the HTTP client of client, used to download streams.
+	httpClient *http.Client
+	// format is the output format.
+	format string
+)
+
+// loadSettings loads the configuration file of the current profile and binds
+// the environment variables.
+func loadSettings() {
+	var err error
+	cfgPath, err = profilePath(profile)
+	if err != nil {
+		Warnf("Failed to load configuration: %s", err)
+	}
+	// legacy files belong to the default profile
+	var dir string
+	if cfgPath != "" && profile == defaultProfile {
+		dir = filepath.Dir(cfgPath)
+	}
+	var migrateErr error
+	cfg, err = loadConfig(cfgPath, func(c *config.Config) error {
+		migrateErr = migrateSettings(c, dir)
+		return migrateErr
+	})
+	if migrateErr != nil {
+		Warnf("Failed to migrate configuration: %s", migrateErr)
+	} else if err != nil {
+		Warnf("Failed to load configuration: %s", err)
+	}
+	cfg.BindEnv(envPrefix)
+}
+
+// versionKey is the key of schema version of the configuration file.
+const versionKey = "version"
+
+// migrations upgrade the configuration from version i to i+1, the current
+// version is len(migrations).
+var migrations = []func(c *config.Config, dir string) error{
+	migrateLegacyFiles,
+}
+
+// migrateSettings upgrades the configuration to the current version.
+func migrateSettings(c *config.Config, dir string) error {
+	version := c.GetInt(versionKey, 0)
+	if version > len(migrations) {
+		return fmt.Errorf("unsupported version %d", version)
+	}
+	for ; version < len(migrations); version++ {
+		if err := migrations[version](c, dir); err != nil {
+			return err
+		}
+		c.Set(versionKey, version+1)
+	}
+	return nil
+}
+
+// migrateLegacyFiles imports settings from the per-command configuration
+// files play.json and info.json of version 0.
+func migrateLegacyFiles(c *config.Config, dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	// play.json has higher priority
+	for _, name := range []string{"play.json", "info.json"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		legacy, err := config.From(path)
+		if err != nil {
+			if err == config.ErrEmptyFile {
+				continue
+			}
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		for _, key := range []string{channelIDKey, playerKey, proxyPortKey} {
+			if _, found := c.Get(key); found {
+				continue
+			}
+			if v, found := legacy.Get(key); found {
+				c.Set(key, v)
+			}
+		}
+	}
+	return nil
+}
+
+// globalFlags defines the flags for all commands. The flag values override
+// the values from environment variables, configuration file and defaults.
+func globalFlags(fs *flag.FlagSet) func() {
+	fs.StringVar(&profile, "profile", profile, "Name of configuration profile")
+	endpoint := fs.String("endpoint", cfg.GetString(endpointKey, hiradio.DefaultClient.Endpoint), "Endpoint of Hichannel API")
+	timeout := fs.Duration("timeout", cfg.GetDuration(timeoutKey, defaultTimeout), "Timeout of API requests")
+	userAgent := fs.String("user-agent", cfg.GetString(userAgentKey, hiradio.DefaultClient.UserAgent), "User agent of API requests")
+	fs.StringVar(&format, "format", cfg.GetString(formatKey, defaultFormat), "Output format: text or json")
+	interval := fs.Duration("request-interval", cfg.GetDuration(requestIntervalKey, 0), "Average interval between API requests, 0 means unlimited")
+	burst := fs.Int("request-burst", cfg.GetInt(requestBurstKey, defaultBurst), "Maximum number of API requests sent at once when limited by -request-interval")
+	debug := fs.Bool("debug", false, "Log API requests with the timings of DNS lookup, connection, TLS handshake and first response byte")
+
+	return func() {
+		if format != "text" && format != "json" {
+			Fatalf("Unknown output format: %s", format)
+		}
+		httpClient = &http.Client{Timeout: *timeout}
+		client = hiradio.NewClient(httpClient)
+		client.Endpoint = *endpoint
+		client.UserAgent = *userAgent
+		client.Use(metricsRequests)
+		if *interval > 0 {
+			client.Limiter = hiradio.NewRateLimiter(float64(time.Second)/float64(*interval), *burst)
+		}
+		if *debug {
+			client.Use(debugRequests)
+		}
+	}
+}
+
+// globalFlagSet returns the global flags with default values, for scanning
+// the arguments before the configuration is loaded.
+func globalFlagSet() *flag.FlagSet {
+	loaded, name, f := cfg, profile, format
+	defer func() { cfg, profile, format = loaded, name, f }()
+	cfg = config.New()
+	fs := flag.NewFlagSet("hiradio", flag.ContinueOnError)
+	globalFlags(fs)
+	return fs
+}
+
+// envUsage returns the description of environment variables.
+func envUsage() string {
+	buf := []string{fmt.Sprintf("    %-28s %s", profileEnv, "Name of configuration profile")}
+	for _, s := range settings {
+		buf = append(buf, fmt.Sprintf("    %-28s %s", config.EnvName(envPrefix, s.key), s.usage))
+	}
+	return strings.Join(buf, "\n")
+}
+
+// saveFlags saves the values of flags which are set on the command line.
+func saveFlags(fs *flag.FlagSet, keys map[string]string) {
+	fs.Visit(func(f *flag.Flag) {
+		key, found := keys[f.Name]
+		if !found {
+			return
+		}
+		if g, ok := f.Value.(flag.Getter); ok {
+			cfg.Set(key, g.Get())
+		}
+	})
+}
+
+// saveSettings saves the configuration to file.
+func saveSettings() {
+	if cfgPath == "" {
+		return
+	}
+	if err := config.SaveTo(cfgPath, cfg); err != nil {
+		Warnf("Failed to save configuration: %s", err)
+	}
+}
+
 func configPath(name string) (string, error) {
 	dir, err := goappdata.CreatePath("hiradio")
 	if err != nil {
@@ -16,16 +343,38 @@ func configPath(name string) (string, error) {
 	return filepath.Join(dir, name), nil
 }
 
-func loadConfig(path string) (*config.Config, error) {
+// loadConfig loads the configuration file at path and upgrades it by migrate,
+// the file is locked during the upgrade. An empty configuration is returned
+// if the file cannot be loaded.
+func loadConfig(path string, migrate func(c *config.Config) error) (*config.Config, error) {
 	if path == "" {
-		return config.New(), nil
+		c := config.New()
+		return c, migrate(c)
+	}
+	c, err := config.Update(path, migrate)
+	if c != nil {
+		return c, err
 	}
-	cfg, err := config.From(path)
+
+	// the file cannot be locked, load it without the upgrade
+	c, err = config.From(path)
 	if err != nil {
 		if err == config.ErrEmptyFile {
 			return config.New(), nil
 		}
+		if ce, ok := err.(*config.CorruptError); ok && ce.Recovered {
+			return c, err
+		}
 		return config.New(), err
 	}
-	return cfg, nil
+	return c, nil
+}
+
+// printJSON prints v in JSON format.
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		Fatal(err)
+	}
 }
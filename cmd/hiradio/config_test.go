@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/config"
+)
+
+func TestMigrateLegacyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hiradio")
+	if err != nil {
+		t.Fatalf("unexpected error on TempDir: %s", err)
+	}
+	files := map[string]string{
+		"play.json": `{"channelID":222,"player":"/usr/bin/vlc","proxyPort":8080}`,
+		"info.json": `{"channelID":156}`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error on WriteFile: %s", err)
+		}
+	}
+
+	c := config.New()
+	c.Set(proxyPortKey, 1077)
+	if err := migrateSettings(c, dir); err != nil {
+		t.Fatalf("unexpected error on migrateSettings: %s", err)
+	}
+
+	if got := c.GetInt(versionKey, 0); got != len(migrations) {
+		t.Fatalf("got version %d, want %d", got, len(migrations))
+	}
+	if got := c.GetInt(channelIDKey, -1); got != 222 {
+		t.Fatalf("got %d, want %d", got, 222)
+	}
+	if got := c.GetString(playerKey, ""); got != "/usr/bin/vlc" {
+		t.Fatalf("got %s, want %s", got, "/usr/bin/vlc")
+	}
+	// existing settings are kept
+	if got := c.GetInt(proxyPortKey, -1); got != 1077 {
+		t.Fatalf("got %d, want %d", got, 1077)
+	}
+
+	// migrated only once
+	c.Unset(channelIDKey)
+	if err := migrateSettings(c, dir); err != nil {
+		t.Fatalf("unexpected error on migrateSettings: %s", err)
+	}
+	if _, found := c.Get(channelIDKey); found {
+		t.Fatal("settings should not be migrated again")
+	}
+}
+
+func TestSettingParse(t *testing.T) {
+	tests := []struct {
+		key  string
+		text string
+		want interface{}
+	}{
+		{proxyPortKey, "8080", 8080},
+		{timeoutKey, "30s", 30 * time.Second},
+		{favoritesKey, "222, 156", []int{222, 156}},
+		{playersKey, `{"mpv":"mpv {url}"}`, map[string]string{"mpv": "mpv {url}"}},
+		{playerKey, "mpv", "mpv"},
+	}
+	for _, test := range tests {
+		s, found := findSetting(test.key)
+		if !found {
+			t.Fatalf("setting %s not found", test.key)
+		}
+		got, err := s.parse(test.text)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("got %#v, want %#v", got, test.want)
+		}
+	}
+
+	s, _ := findSetting(proxyPortKey)
+	if _, err := s.parse("port"); err == nil {
+		t.Fatal("expected error on parsing invalid int")
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/parkghost/hiradio/cmd/internal/config"
+)
+
+func configCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `usage: hiradio config get KEY
+       hiradio config set KEY VALUE
+       hiradio config unset KEY
+       hiradio config list
+       hiradio config path
+       hiradio config edit
+       hiradio config profiles [create NAME | copy SRC DST]
+
+Inspect or change settings
+
+The keys are:
+%s
+`, settingsUsage())
+		os.Exit(1)
+	}
+
+	// parse arguments
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return
+	}
+	sub, subArgs := fs.Arg(0), fs.Args()[1:]
+	if sub == "profiles" {
+		configProfiles(subArgs, fs.Usage)
+		return
+	}
+	nargs := map[string]int{"get": 1, "set": 2, "unset": 1, "list": 0, "path": 0, "edit": 0}
+	n, found := nargs[sub]
+	if !found || len(subArgs) != n {
+		fs.Usage()
+		return
+	}
+
+	switch sub {
+	case "get":
+		configGet(subArgs[0])
+	case "set":
+		configSet(subArgs[0], subArgs[1])
+	case "unset":
+		configUnset(subArgs[0])
+	case "list":
+		configList()
+	case "path":
+		if cfgPath == "" {
+			Fatal("Configuration file is unavailable")
+		}
+		fmt.Println(cfgPath)
+	case "edit":
+		configEdit()
+	}
+}
+
+func settingsUsage() string {
+	var buf []string
+	for _, s := range settings {
+		buf = append(buf, fmt.Sprintf("    %-12s %-9s %s", s.key, s.kind, s.usage))
+	}
+	return strings.Join(buf, "\n")
+}
+
+func mustFindSetting(key string) setting {
+	s, found := findSetting(key)
+	if !found {
+		Fatalf("Unknown key: %s", key)
+	}
+	return s
+}
+
+func configGet(key string) {
+	s := mustFindSetting(key)
+	v, found := s.value(cfg)
+	if !found {
+		os.Exit(1)
+	}
+	fmt.Println(formatSettingValue(v))
+}
+
+func configSet(key, text string) {
+	s := mustFindSetting(key)
+	v, err := s.parse(text)
+	if err != nil {
+		Fatalf("Invalid value of %s: %s", key, err)
+	}
+	cfg.Set(key, v)
+	saveSettings()
+}
+
+func configUnset(key string) {
+	mustFindSetting(key)
+	cfg.Unset(key)
+	saveSettings()
+}
+
+func configList() {
+	for _, s := range settings {
+		v, found := s.value(cfg)
+		if !found {
+			continue
+		}
+		source := "default"
+		if _, overridden := cfg.LookupEnv(s.key); overridden {
+			source = "env " + config.EnvName(envPrefix, s.key)
+		} else if _, stored := cfg.Get(s.key); stored {
+			source = "file"
+		}
+		fmt.Printf("%s=%s  (%s)\n", s.key, formatSettingValue(v), source)
+	}
+}
+
+func configEdit() {
+	if cfgPath == "" {
+		Fatal("Configuration file is unavailable")
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	args, err := splitArgs(editor)
+	if err != nil || len(args) == 0 {
+		Fatalf("Invalid editor: %s", editor)
+	}
+
+	// ensure the file exists
+	if _, err := os.Stat(cfgPath); os.IsNotExist(err) {
+		if err := ioutil.WriteFile(cfgPath, []byte("{}\n"), 0644); err != nil {
+			Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(args[0], append(args[1:], cfgPath)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		Fatalf("Failed to run editor: %s", err)
+	}
+
+	// validate the content
+	data, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		Fatal(err)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		Fatalf("Invalid configuration, fix it by \"hiradio config edit\": %s", err)
+	}
+}
+
+func configProfiles(args []string, usage func()) {
+	switch {
+	case len(args) == 0:
+		names, err := listProfiles()
+		if err != nil {
+			Fatal(err)
+		}
+		for _, name := range names {
+			cursor := "  "
+			if name == profile {
+				cursor = "* "
+			}
+			fmt.Println(cursor + name)
+		}
+	case args[0] == "create" && len(args) == 2:
+		if err := createProfile(args[1]); err != nil {
+			Fatalf("Failed to create profile: %s", err)
+		}
+	case args[0] == "copy" && len(args) == 3:
+		if err := copyProfile(args[1], args[2]); err != nil {
+			Fatalf("Failed to copy profile: %s", err)
+		}
+	default:
+		usage()
+	}
+}
+
+// formatSettingValue formats v for displaying, strings are not quoted.
+func formatSettingValue(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case fmt.Stringer:
+		return vv.String()
+	case []int:
+		s := make([]string, 0, len(vv))
+		for _, i := range vv {
+			s = append(s, fmt.Sprint(i))
+		}
+		return strings.Join(s, ",")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
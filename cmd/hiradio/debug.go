@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parkghost/hiradio"
+)
+
+// debugRequests is a middleware logging each API request with the timings of
+// its phases.
+func debugRequests(next hiradio.Doer) hiradio.Doer {
+	return hiradio.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		t := new(requestTimings)
+		start := time.Now()
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), t.trace(start)))
+		resp, err := next.Do(req)
+		t.mu.Lock()
+		t.total = time.Since(start)
+		t.mu.Unlock()
+		if err != nil {
+			Debugf("%s %s: %s (%s)", req.Method, req.URL, err, t)
+			return resp, err
+		}
+		Debugf("%s %s: %s (%s)", req.Method, req.URL, resp.Status, t)
+		return resp, err
+	})
+}
+
+// requestTimings are the elapsed times of the phases of a request since its
+// start, they are zero if the phase is skipped. The trace hooks may be called
+// from other goroutines.
+type requestTimings struct {
+	mu           sync.Mutex
+	dns          time.Duration
+	connect      time.Duration
+	tlsHandshake time.Duration
+	firstByte    time.Duration
+	total        time.Duration
+	reused       bool
+}
+
+func (t *requestTimings) trace(start time.Time) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dns = time.Since(start)
+			t.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			t.connect = time.Since(start)
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.tlsHandshake = time.Since(start)
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.firstByte = time.Since(start)
+			t.mu.Unlock()
+		},
+	}
+}
+
+func (t *requestTimings) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var phases []string
+	if t.reused {
+		phases = append(phases, "reused connection")
+	}
+	for _, p := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"dns", t.dns},
+		{"connect", t.connect},
+		{"tls", t.tlsHandshake},
+		{"first byte", t.firstByte},
+		{"total", t.total},
+	} {
+		if p.d > 0 {
+			phases = append(phases, fmt.Sprintf("%s %s", p.name, p.d/time.Microsecond*time.Microsecond))
+		}
+	}
+	return strings.Join(phases, ", ")
+}
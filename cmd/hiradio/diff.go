@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/parkghost/hiradio"
+)
+
+const catalogFile = "catalog.json"
+
+func diffCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	save := fs.Bool("save", true, "Save the current catalog as the snapshot for next diff")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio diff [options]
+
+Display channels added, removed, renamed or re-typed since the last snapshot
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return
+	}
+
+	path, err := configPath(catalogFile)
+	if err != nil {
+		Fatalf("Failed to load catalog: %s", err)
+	}
+	last, err := loadCatalog(path)
+	if err != nil {
+		Fatalf("Failed to load catalog: %s", err)
+	}
+
+	current, err := client.GetCatalog()
+	if err != nil {
+		Fatal(err)
+	}
+
+	if last == nil {
+		fmt.Printf("No previous snapshot, %d channels in current catalog\n", len(current.Channels))
+	} else {
+		d := last.Diff(current)
+		if format == "json" {
+			printJSON(d)
+		} else {
+			printCatalogDiff(last, d)
+		}
+	}
+
+	if *save {
+		if err := saveCatalog(path, current); err != nil {
+			Fatalf("Failed to save catalog: %s", err)
+		}
+	}
+}
+
+func loadCatalog(path string) (*hiradio.Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return hiradio.LoadCatalog(f)
+}
+
+// saveCatalog writes the catalog to a temporary file and renames it to path,
+// so a crash never leaves a truncated catalog.
+func saveCatalog(path string, cat *hiradio.Catalog) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err := cat.Save(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func printCatalogDiff(last *hiradio.Catalog, d *hiradio.CatalogDiff) {
+	fmt.Printf("Since %s:\n", last.Time.Local().Format("2006-01-02 15:04"))
+	if d.Empty() {
+		fmt.Println("No changes")
+		return
+	}
+	for _, c := range d.Added {
+		fmt.Printf("+ %4d  %s  %s\n", c.ID, c.Type, c.Title)
+	}
+	for _, c := range d.Removed {
+		fmt.Printf("- %4d  %s  %s\n", c.ID, c.Type, c.Title)
+	}
+	for _, c := range d.Renamed {
+		fmt.Printf("~ %4d  %s -> %s\n", c.New.ID, c.Old.Title, c.New.Title)
+	}
+	for _, c := range d.Retyped {
+		fmt.Printf("~ %4d  %s  %s -> %s\n", c.New.ID, c.New.Title, c.Old.Type, c.New.Type)
+	}
+}
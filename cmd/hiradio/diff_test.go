@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parkghost/hiradio"
+)
+
+func TestSaveCatalog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hiradio")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, catalogFile)
+
+	for _, title := range []string{"HitFm", "HitFm聯播網"} {
+		want := &hiradio.Catalog{
+			Time:     time.Date(2015, 3, 1, 8, 0, 0, 0, time.UTC),
+			Channels: []hiradio.CatalogChannel{{Channel: hiradio.Channel{ID: 222, Title: title}}},
+		}
+		if err := saveCatalog(path, want); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		got, err := loadCatalog(path)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	}
+
+	// no temporary files are left
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("unexpected files: %v", files)
+	}
+}
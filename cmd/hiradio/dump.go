@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/archive"
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+	"github.com/parkghost/hiradio/cmd/internal/tag"
+)
+
+var errNoData = errors.New("no data received")
+
+// streamDump saves the stream of the channel into a file while playing.
+type streamDump struct {
+	channelID int
+	name      string
+	start     time.Time
+	err       error
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// startDump starts saving the stream of the channel into the file.
+func startDump(channelID int, name string) (*streamDump, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	d := &streamDump{
+		channelID: channelID,
+		name:      name,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go func() {
+		defer close(d.done)
+		recordStream(channelID, newStream(channelID), d.stop, func(seg hls.Segment, data []byte) error {
+			if d.start.IsZero() {
+				d.start = time.Now()
+			}
+			_, err := f.Write(data)
+			return err
+		})
+		d.err = f.Close()
+	}()
+	return d, nil
+}
+
+// Close stops saving the stream and tags the file with the channel and the
+// programs in it.
+func (d *streamDump) Close() error {
+	close(d.stop)
+	<-d.done
+	if d.err != nil {
+		return d.err
+	}
+	if d.start.IsZero() {
+		os.Remove(d.name)
+		return errNoData
+	}
+	return writeTags(d.name, d.channelID, d.start, time.Now())
+}
+
+// writeTags tags the file recorded from the channel in [start, stop), a
+// chapter is added for each program if there are several programs.
+func writeTags(name string, channelID int, start, stop time.Time) error {
+	info, err := client.GetChannelInfo(channelID)
+	if err != nil {
+		return err
+	}
+	var programs []archive.Program
+	for day := start.AddDate(0, 0, -1); !day.After(stop); day = day.AddDate(0, 0, 1) {
+		programs = append(programs, archive.ResolvePrograms(day, info.List)...)
+	}
+
+	t := tag.Tags{
+		Title:  info.Title,
+		Artist: info.Title,
+		Album:  info.Title,
+		Date:   start,
+	}
+	chapters := recordingChapters(programs, start, stop)
+	if len(chapters) > 0 {
+		t.Title = chapters[0].Title
+	}
+	if len(chapters) > 1 {
+		t.Chapters = chapters
+	}
+	if image := channelImage(channelID, info); image != "" {
+		data, err := hls.Get(httpClient, image)
+		if err != nil {
+			Warnf("Failed to fetch station logo: %s", err)
+		} else {
+			t.Image = data
+			t.ImageType = http.DetectContentType(data)
+		}
+	}
+	return tag.WriteFile(name, t)
+}
+
+// recordingChapters returns the chapters of the programs in the recording of
+// [start, stop).
+func recordingChapters(programs []archive.Program, start, stop time.Time) []tag.Chapter {
+	var chapters []tag.Chapter
+	for _, p := range programs {
+		from, to := p.Start, p.Stop
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(stop) {
+			to = stop
+		}
+		if !from.Before(to) {
+			continue
+		}
+		chapters = append(chapters, tag.Chapter{
+			Title: p.Name,
+			Start: from.Sub(start),
+			End:   to.Sub(start),
+		})
+	}
+	return chapters
+}
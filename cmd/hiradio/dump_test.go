@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parkghost/hiradio"
+	"github.com/parkghost/hiradio/cmd/internal/archive"
+	"github.com/parkghost/hiradio/cmd/internal/tag"
+)
+
+func TestRecordingChapters(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2015, 3, 1, h, m, 0, 0, time.UTC) }
+	programs := []archive.Program{
+		{Name: "HIT DJ 早安", Start: at(7, 0), Stop: at(10, 0)},
+		{Name: "週日 HIT DJ", Start: at(10, 0), Stop: at(12, 0)},
+		{Name: "HITO唱片行", Start: at(12, 0), Stop: at(14, 0)},
+	}
+	got := recordingChapters(programs, at(9, 30), at(12, 15))
+	want := []tag.Chapter{
+		{Title: "HIT DJ 早安", Start: 0, End: 30 * time.Minute},
+		{Title: "週日 HIT DJ", Start: 30 * time.Minute, End: 150 * time.Minute},
+		{Title: "HITO唱片行", Start: 150 * time.Minute, End: 165 * time.Minute},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestChannelImage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{
+    "pageNo": 1,
+    "pageSize": 1,
+    "list":[
+        {
+            "channel_id": "1471",
+            "channel_image": "14a7b76cf9c00000340a.jpg",
+            "channel_title": "NER教育電臺 臺北總臺AM",
+            "isChannel": true,
+            "program_name": "校園健康筆記",
+            "radio_type": "4"
+        }
+    ]
+}`))
+	}))
+	defer server.Close()
+	defer func(c *hiradio.Client) { client = c }(client)
+	client = hiradio.NewClient(server.Client())
+	client.Endpoint = server.URL + "/"
+
+	info := &hiradio.ChannelInfo{Image: "14abcde694d00000b2fc.jpg"}
+	for _, c := range []struct {
+		channelID int
+		want      string
+	}{
+		{1471, "http://hichannel.hinet.net/upload/radio/channel/14a7b76cf9c00000340a.jpg"},
+		// missing from the channel list
+		{222, "http://hichannel.hinet.net/upload/radio/channel/14abcde694d00000b2fc.jpg"},
+	} {
+		if got := channelImage(c.channelID, info); got != c.want {
+			t.Errorf("channelImage(%d) got %q, want %q", c.channelID, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parkghost/hiradio"
+)
+
+const (
+	guideSlot       = 30 // minutes per column
+	guideTitleWidth = 24
+	minutesPerDay   = 24 * 60
+)
+
+func guideCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("guide", flag.ExitOnError)
+	hours := fs.Int("hours", 3, "Number of hours to display")
+	width := fs.Int("width", 14, "Width of a half-hour column")
+	radioType := fs.String("type", "", "Display channels of the radio type only, e.g. 1 or 音樂")
+	favoritesOnly := fs.Bool("favorites", false, "Display favorite channels only")
+	concurrency := fs.Int("concurrency", defaultConcurrency, "Maximum number of concurrent requests")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio guide [options]
+
+Display a program guide of all channels from the current half hour
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return
+	}
+	if *hours < 1 || *width < 4 || *concurrency < 1 {
+		fs.Usage()
+		return
+	}
+
+	channels, err := client.ListChannels()
+	if err != nil {
+		Fatal(err)
+	}
+	if *radioType != "" {
+		rt, err := parseRadioType(*radioType)
+		if err != nil {
+			Fatal(err)
+		}
+		channels = filterRadioType(channels, rt)
+	}
+	if *favoritesOnly {
+		channels = filterChannels(channels, cfg.GetIntSlice(favoritesKey, nil))
+	}
+	sort.Sort(channelsByType(channels))
+
+	infos := fetchChannelInfos(channels, *concurrency, client.GetChannelInfo)
+	if format == "json" {
+		printJSON(infos)
+		return
+	}
+	printGuide(infos, time.Now(), *hours*60/guideSlot, *width)
+}
+
+// parseRadioType parses a radio type from its number or name.
+func parseRadioType(s string) (hiradio.RadioType, error) {
+	if n, err := strconv.Atoi(s); err == nil && hiradio.RadioType(n).String() != "Unknown" {
+		return hiradio.RadioType(n), nil
+	}
+	for rt := hiradio.RadioType(1); rt.String() != "Unknown"; rt++ {
+		if rt.String() == s {
+			return rt, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown radio type %q", s)
+}
+
+func filterRadioType(channels []hiradio.Channel, rt hiradio.RadioType) []hiradio.Channel {
+	var list []hiradio.Channel
+	for _, c := range channels {
+		if c.Type == rt {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+type channelsByType []hiradio.Channel
+
+func (s channelsByType) Len() int      { return len(s) }
+func (s channelsByType) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s channelsByType) Less(i, j int) bool {
+	if s[i].Type != s[j].Type {
+		return s[i].Type < s[j].Type
+	}
+	return s[i].ID < s[j].ID
+}
+
+func printGuide(infos []channelInfo, now time.Time, cols, width int) {
+	minute := now.Hour()*60 + now.Minute()
+	start := minute - minute%guideSlot
+	indent := strings.Repeat(" ", 6+guideTitleWidth)
+
+	// cursor at the current time
+	offset := (minute - start) * width / guideSlot
+	fmt.Printf("%s%s▼\n", indent, strings.Repeat(" ", offset))
+
+	// time header
+	var header []string
+	for i := 0; i < cols; i++ {
+		m := (start + i*guideSlot) % minutesPerDay
+		header = append(header, padWidth(fmt.Sprintf("|%02d:%02d", m/60, m%60), width))
+	}
+	fmt.Printf("%s%s\n", indent, strings.Join(header, ""))
+
+	for _, c := range infos {
+		fmt.Printf("%4d  %s%s\n",
+			c.ID,
+			padWidth(truncateWidth(c.Info.Title, guideTitleWidth-1), guideTitleWidth),
+			guideRow(c.Info.List, start, cols, width))
+	}
+}
+
+// guideRow renders programs in cols half-hour columns from start (minutes
+// since midnight). A program occupies the cells of its time slot and begins
+// with a '|'.
+func guideRow(programs []hiradio.Program, start, cols, width int) string {
+	total := cols * width
+	end := start + cols*guideSlot
+
+	// the schedule repeats daily, the window may pass midnight
+	var cells []guideCell
+	for _, p := range programs {
+		from, to, ok := programSpan(p)
+		if !ok {
+			continue
+		}
+		// a program passing midnight also covers the early morning
+		for _, shift := range []int{-minutesPerDay, 0, minutesPerDay} {
+			f, t := from+shift, to+shift
+			if t <= start || f >= end {
+				continue
+			}
+			x0 := (f - start) * width / guideSlot
+			if x0 < 0 {
+				x0 = 0
+			}
+			x1 := (t - start) * width / guideSlot
+			if x1 > total {
+				x1 = total
+			}
+			cells = append(cells, guideCell{x0, x1, p.Name})
+		}
+	}
+	sort.Stable(guideCells(cells))
+
+	var buf []string
+	x := 0
+	for _, c := range cells {
+		if c.x0 < x {
+			c.x0 = x
+		}
+		if c.x1 <= c.x0 {
+			continue
+		}
+		if c.x0 > x {
+			buf = append(buf, strings.Repeat(" ", c.x0-x))
+		}
+		buf = append(buf, padWidth(truncateWidth("|"+c.name, c.x1-c.x0), c.x1-c.x0))
+		x = c.x1
+	}
+	return strings.TrimRight(strings.Join(buf, ""), " ")
+}
+
+type guideCell struct {
+	x0, x1 int
+	name   string
+}
+
+type guideCells []guideCell
+
+func (s guideCells) Len() int           { return len(s) }
+func (s guideCells) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s guideCells) Less(i, j int) bool { return s[i].x0 < s[j].x0 }
+
+// programSpan returns start and end of the program in minutes since
+// midnight. The end is on the next day if the program passes midnight.
+func programSpan(p hiradio.Program) (start, end int, ok bool) {
+	start, err := clockMinutes(p.StartTime)
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err = clockMinutes(p.EndTime)
+	if err != nil {
+		return 0, 0, false
+	}
+	if end <= start {
+		end += minutesPerDay
+	}
+	return start, end, true
+}
+
+func clockMinutes(s string) (int, error) {
+	if s == "24:00" {
+		return minutesPerDay, nil
+	}
+	hour, min, err := parseClock(s)
+	if err != nil {
+		return 0, err
+	}
+	return hour*60 + min, nil
+}
+
+// truncateWidth returns the longest prefix of s not wider than w.
+func truncateWidth(s string, w int) string {
+	var n int
+	for i, r := range s {
+		rw := stringWidth(string(r))
+		if n+rw > w {
+			return s[:i]
+		}
+		n += rw
+	}
+	return s
+}
+
+// padWidth pads s with spaces to width w.
+func padWidth(s string, w int) string {
+	if n := stringWidth(s); n < w {
+		return s + strings.Repeat(" ", w-n)
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/parkghost/hiradio"
+)
+
+func TestGuideRow(t *testing.T) {
+	programs := []hiradio.Program{
+		{StartTime: "00:00", EndTime: "06:00", Name: "深夜音樂"},
+		{StartTime: "17:00", EndTime: "18:00", Name: "週日 HIT DJ"},
+		{StartTime: "18:00", EndTime: "20:00", Name: "HITO唱片行"},
+		{StartTime: "23:00", EndTime: "24:00", Name: "Night"},
+	}
+
+	cases := []struct {
+		start, cols int
+		want        string
+	}{
+		// 17:30 ~ 19:30
+		{17*60 + 30, 4, "|週日 |HITO唱片行"},
+		// 23:00 ~ 00:30, passes midnight
+		{23 * 60, 3, "|Night      |深夜"},
+		// 15:00 ~ 16:00, no programs
+		{15 * 60, 2, ""},
+	}
+	for _, c := range cases {
+		got := guideRow(programs, c.start, c.cols, 6)
+		if got != c.want {
+			t.Errorf("guideRow(%d, %d): got %q, want %q", c.start, c.cols, got, c.want)
+		}
+	}
+
+	// programs passing midnight
+	programs = []hiradio.Program{
+		{StartTime: "01:00", EndTime: "06:00", Name: "深夜音樂"},
+		{StartTime: "23:00", EndTime: "01:00", Name: "Night"},
+	}
+	cases = []struct {
+		start, cols int
+		want        string
+	}{
+		// 00:00 ~ 01:30
+		{0, 3, "|Night      |深夜"},
+		// 22:30 ~ 00:00
+		{22*60 + 30, 3, "      |Night"},
+	}
+	for _, c := range cases {
+		got := guideRow(programs, c.start, c.cols, 6)
+		if got != c.want {
+			t.Errorf("guideRow(%d, %d): got %q, want %q", c.start, c.cols, got, c.want)
+		}
+	}
+}
+
+func TestProgramSpan(t *testing.T) {
+	cases := []struct {
+		start, end string
+		from, to   int
+		ok         bool
+	}{
+		{"17:00", "18:30", 17 * 60, 18*60 + 30, true},
+		{"23:00", "01:00", 23 * 60, 25 * 60, true},
+		{"23:00", "24:00", 23 * 60, 24 * 60, true},
+		{"", "18:00", 0, 0, false},
+	}
+	for _, c := range cases {
+		from, to, ok := programSpan(hiradio.Program{StartTime: c.start, EndTime: c.end})
+		if from != c.from || to != c.to || ok != c.ok {
+			t.Errorf("programSpan(%s, %s): got %d, %d, %v, want %d, %d, %v",
+				c.start, c.end, from, to, ok, c.from, c.to, c.ok)
+		}
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	cases := []struct {
+		s    string
+		w    int
+		want string
+	}{
+		{"HitFm", 3, "Hit"},
+		{"中廣流行網", 5, "中廣"},
+		{"中廣流行網", 20, "中廣流行網"},
+		{"i like", 0, ""},
+	}
+	for _, c := range cases {
+		if got := truncateWidth(c.s, c.w); got != c.want {
+			t.Errorf("truncateWidth(%q, %d): got %q, want %q", c.s, c.w, got, c.want)
+		}
+	}
+}
+
+func TestParseRadioType(t *testing.T) {
+	for _, s := range []string{"1", "音樂"} {
+		rt, err := parseRadioType(s)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if rt != 1 {
+			t.Fatalf("got %v, want %v", rt, hiradio.RadioType(1))
+		}
+	}
+	if _, err := parseRadioType("8"); err == nil {
+		t.Fatal("expected error for unknown radio type")
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/history"
+)
+
+const historyFile = "history.jsonl"
+
+// recordSession appends the session to the listening history.
+func recordSession(s history.Session) {
+	path, err := configPath(historyFile)
+	if err != nil {
+		Warnf("Failed to record history: %s", err)
+		return
+	}
+	if err := history.Append(path, s); err != nil {
+		Warnf("Failed to record history: %s", err)
+	}
+}
+
+func loadHistory() []history.Session {
+	path, err := configPath(historyFile)
+	if err != nil {
+		Fatalf("Failed to load history: %s", err)
+	}
+	sessions, err := history.Load(path)
+	if err != nil {
+		Fatalf("Failed to load history: %s", err)
+	}
+	return sessions
+}
+
+func historyCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	n := fs.Int("n", 20, "Number of sessions to display")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio history [options]
+
+Display recent listening sessions
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 || *n < 1 {
+		fs.Usage()
+		return
+	}
+
+	sessions := loadHistory()
+	if len(sessions) > *n {
+		sessions = sessions[len(sessions)-*n:]
+	}
+	// most recent first
+	for i, j := 0, len(sessions)-1; i < j; i, j = i+1, j-1 {
+		sessions[i], sessions[j] = sessions[j], sessions[i]
+	}
+
+	if format == "json" {
+		printJSON(sessions)
+		return
+	}
+	for _, s := range sessions {
+		var programs []string
+		for _, p := range s.Programs {
+			programs = append(programs, p.Name)
+		}
+		fmt.Printf("%s  %s  %4d  %s  %s\n",
+			s.Start.Local().Format("2006-01-02 15:04"),
+			formatElapsed(s.Duration()),
+			s.ChannelID,
+			s.Title,
+			strings.Join(programs, ", "))
+	}
+}
+
+func statsCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	by := fs.String("by", history.ByChannel, "Group by channel, type or program")
+	since := fs.Duration("since", 0, "Only count sessions in the duration, e.g. 720h (all sessions if zero)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio stats [options]
+
+Display total listening time
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return
+	}
+	switch *by {
+	case history.ByChannel, history.ByType, history.ByProgram:
+	default:
+		Fatalf("Unknown grouping: %s", *by)
+	}
+
+	sessions := loadHistory()
+	if *since > 0 {
+		sessions = history.Since(sessions, time.Now().Add(-*since))
+	}
+	stats := history.Stats(sessions, *by)
+
+	if format == "json" {
+		printJSON(stats)
+		return
+	}
+	for _, st := range stats {
+		key := st.Key
+		if key == "" {
+			key = "Unknown"
+		}
+		fmt.Printf("%s  %4d  %s\n", formatElapsed(st.Duration), st.Sessions, key)
+	}
+}
+
+func resumeCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("resume", flag.ExitOnError)
+	n := fs.Int("n", 9, "Number of recent channels to choose from")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio resume [options] [-- play options]
+
+Choose a recently played channel and play it
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+
+	recent := history.Recent(loadHistory(), *n)
+	if len(recent) == 0 {
+		Fatal("No listening history")
+	}
+	for i, s := range recent {
+		fmt.Printf("%2d) %4d  %s  (%s)\n", i+1, s.ChannelID, s.Title, s.Stop.Local().Format("2006-01-02 15:04"))
+	}
+	fmt.Print("Choose a channel: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		println()
+		return
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(recent) {
+		Fatalf("Invalid choice: %s", strings.TrimSpace(line))
+	}
+
+	playArgs := append(fs.Args(), strconv.Itoa(recent[choice-1].ChannelID))
+	playCmd(playArgs)
+}
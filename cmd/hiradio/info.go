@@ -6,20 +6,9 @@ import (
 	"os"
 
 	"github.com/parkghost/hiradio"
-	"github.com/parkghost/hiradio/cmd/internal/config"
 )
 
 func infoCmd(args []string) {
-	// load config from file
-	cfgPath, err := configPath("info.json")
-	if err != nil {
-		Warnf("Failed to load configuration: %s", err)
-	}
-	cfg, err := loadConfig(cfgPath)
-	if err != nil {
-		Warnf("Failed to load configuration: %s", err)
-	}
-
 	// flag settings
 	fs := flag.NewFlagSet("info", flag.ExitOnError)
 	fs.Usage = func() {
@@ -35,7 +24,7 @@ Display radio information and program list`)
 		fs.Usage()
 		return
 	}
-	channelID, err := getChannelID(fs.Args(), cfg)
+	channelID, err := getChannelID(fs.Args())
 	if err != nil {
 		if err == errNotFound {
 			fs.Usage()
@@ -45,20 +34,20 @@ Display radio information and program list`)
 	}
 
 	// fetch channel info
-	info, err := hiradio.GetChannelInfo(channelID)
+	info, err := client.GetChannelInfo(channelID)
 	if err != nil {
 		Fatal(err)
 	}
 
-	printChannelInfo(channelID, info)
+	if format == "json" {
+		printJSON(info)
+	} else {
+		printChannelInfo(channelID, info)
+	}
 
 	// save current config
-	if cfgPath != "" {
-		cfg.Set(channelIDKey, channelID)
-		if err := config.SaveTo(cfgPath, cfg); err != nil {
-			Warnf("Failed to save configuration: %s", err)
-		}
-	}
+	cfg.Set(channelIDKey, channelID)
+	saveSettings()
 }
 
 func printChannelInfo(id int, info *hiradio.ChannelInfo) {
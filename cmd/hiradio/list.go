@@ -15,10 +15,14 @@ import (
 func listCmd(args []string) {
 	// flag settings
 	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	favoritesOnly := fs.Bool("favorites", false, "List favorite channels only")
 	fs.Usage = func() {
-		fmt.Fprintln(os.Stderr, `usage: hiradio list
+		fmt.Fprintln(os.Stderr, `usage: hiradio list [options]
 
-List channels information`)
+List channels information
+
+The options are:`)
+		fs.PrintDefaults()
 		os.Exit(1)
 	}
 	fs.Parse(args)
@@ -30,12 +34,12 @@ List channels information`)
 	}
 	rankingsCh := make(chan lrs)
 	go func() {
-		result, err := hiradio.ListRankings()
+		result, err := client.ListRankings()
 		rankingsCh <- lrs{result, err}
 	}()
 
 	// fetch channels
-	channels, err := hiradio.ListChannels()
+	channels, err := client.ListChannels()
 	if err != nil {
 		Fatal(err)
 	}
@@ -45,14 +49,35 @@ List channels information`)
 	}
 
 	// mix channels and rankings
+	if *favoritesOnly {
+		channels = filterChannels(channels, cfg.GetIntSlice(favoritesKey, nil))
+	}
 	rc := newRankedChannels(channels, result.rankings)
 	sort.Sort(rc)
-	printChannelList(rc)
+	if format == "json" {
+		printJSON(rc)
+	} else {
+		printChannelList(rc)
+	}
+}
+
+// filterChannels returns the channels in channelIDs.
+func filterChannels(channels []hiradio.Channel, channelIDs []int) []hiradio.Channel {
+	var list []hiradio.Channel
+	for _, c := range channels {
+		for _, id := range channelIDs {
+			if c.ID == id {
+				list = append(list, c)
+				break
+			}
+		}
+	}
+	return list
 }
 
 type rankedChannel struct {
 	hiradio.Channel
-	Ranking int
+	Ranking int `json:"ranking"`
 }
 
 type rankedChannels []rankedChannel
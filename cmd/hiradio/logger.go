@@ -29,3 +29,7 @@ func Warnf(format string, args ...interface{}) {
 func Warn(args ...interface{}) {
 	print("Warning", fmt.Sprint(args...))
 }
+
+func Debugf(format string, args ...interface{}) {
+	print("Debug", fmt.Sprintf(format, args...))
+}
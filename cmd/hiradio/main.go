@@ -17,6 +17,23 @@ var commands = []command{
 	{"list", "List radio stations", listCmd},
 	{"info", "Display radio information and program list", infoCmd},
 	{"play", "Play radio on player", playCmd},
+	{"timeshift", "Pause, rewind or resume the playing radio", timeshiftCmd},
+	{"alarm", "Play radio on player at the given time", alarmCmd},
+	{"guide", "Display a program guide of all channels", guideCmd},
+	{"search", "Search programs of all channels", searchCmd},
+	{"archive", "Record channels and serve past programs", archiveCmd},
+	{"podcast", "Record programs and serve them as podcast feeds", podcastCmd},
+	{"probe", "Check the stream health of channels", probeCmd},
+	{"monitor", "Alert on dead air and outages of streams", monitorCmd},
+	{"capture", "Capture the live stream into a file with a completeness report", captureCmd},
+	{"recordings", "List, remove or prune recorded programs", recordingsCmd},
+	{"watch", "Watch now-playing program changes", watchCmd},
+	{"diff", "Display channel lineup changes since the last snapshot", diffCmd},
+	{"rankings", "Collect rankings and report ranking trends", rankingsCmd},
+	{"history", "Display recent listening sessions", historyCmd},
+	{"stats", "Display total listening time", statsCmd},
+	{"resume", "Play a recently played channel", resumeCmd},
+	{"config", "Inspect or change settings", configCmd},
 }
 
 func init() {
@@ -32,14 +49,27 @@ The commands are:
 			fmt.Fprintf(os.Stderr, "    %-24s %s\n", c.name, c.description)
 		}
 		fmt.Fprintln(os.Stderr, `
-Use "hiradio command -h" for more information about a command.`)
+Use "hiradio command -h" for more information about a command.
+
+The options are:`)
 		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nThe environment variables are:\n%s\n", envUsage())
 		os.Exit(1)
 	}
 }
 
 func main() {
+	profile = profileFromArgs(globalFlagSet(), os.Args[1:])
+	if err := validateProfile(profile); err != nil {
+		Fatal(err)
+	}
+	if err := checkProfile(profile); err != nil {
+		Fatal(err)
+	}
+	loadSettings()
+	applyGlobalFlags := globalFlags(flag.CommandLine)
 	flag.Parse()
+	applyGlobalFlags()
 	if flag.NArg() == 0 {
 		flag.Usage()
 	}
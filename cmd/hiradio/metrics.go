@@ -0,0 +1,213 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/parkghost/hiradio"
+	"github.com/parkghost/hiradio/cmd/internal/metrics"
+)
+
+// clientTimeout is the time since the last request of a client, after which
+// the client is no longer active.
+const clientTimeout = 1 * time.Minute
+
+// metricsRegistry holds the metrics of the API client and the proxy, they are
+// served by the proxy at /metrics.
+var metricsRegistry = new(metrics.Registry)
+
+var (
+	apiRequests = metricsRegistry.Counter("hiradio_api_requests_total",
+		"Number of Hichannel API requests.", "endpoint", "code")
+	apiErrors = metricsRegistry.Counter("hiradio_api_errors_total",
+		"Number of Hichannel API requests failed by transport errors or non-2xx statuses.", "endpoint")
+	apiDuration = metricsRegistry.Histogram("hiradio_api_request_duration_seconds",
+		"Latency of Hichannel API requests.", metrics.DefaultBuckets, "endpoint")
+
+	proxyRequests = metricsRegistry.Counter("hiradio_proxy_requests_total",
+		"Number of requests served by the proxy.", "route", "code")
+	proxyBytes = metricsRegistry.Counter("hiradio_proxy_response_bytes_total",
+		"Bytes of responses written by the proxy, streams are redirected rather than relayed.", "route")
+	upstreamRefreshes = metricsRegistry.Counter("hiradio_upstream_refreshes_total",
+		"Number of playlist URLs refreshed from the Hichannel API.", "channel_id")
+
+	proxyClients = new(recentClients)
+)
+
+func init() {
+	// Players of /stream request the proxy only when they start or reload
+	// the stream, it is not a count of listeners.
+	metricsRegistry.GaugeFunc("hiradio_proxy_recent_clients",
+		"Number of clients requesting the channel from the proxy in the last minute.",
+		[]string{"channel_id"}, proxyClients.Samples)
+}
+
+// metricsRequests is a middleware recording API requests in the metrics by
+// the endpoint, e.g. play.do. Transport errors and responses other than 2xx
+// are counted as errors. The middleware sees no decoded responses, so a 2xx
+// response which fails to decode or has no result (e.g. play.do without a
+// playlist) is counted as a success.
+func metricsRequests(next hiradio.Doer) hiradio.Doer {
+	return hiradio.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		endpoint := path.Base(req.URL.Path)
+		start := time.Now()
+		resp, err := next.Do(req)
+		apiDuration.Observe(time.Since(start).Seconds(), endpoint)
+		code := "none"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		apiRequests.Inc(endpoint, code)
+		if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+			apiErrors.Inc(endpoint)
+		}
+		return resp, err
+	})
+}
+
+// recentClients tracks the clients of channels by the time of their last
+// requests.
+type recentClients struct {
+	mu   sync.Mutex
+	seen map[int]map[string]time.Time
+}
+
+// Seen records a request of the client for the channel at now.
+func (l *recentClients) Seen(channelID int, client string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.seen == nil {
+		l.seen = make(map[int]map[string]time.Time)
+	}
+	if l.seen[channelID] == nil {
+		l.seen[channelID] = make(map[string]time.Time)
+	}
+	l.seen[channelID][client] = now
+}
+
+// Count returns the number of active clients of each channel at now and
+// forgets inactive ones.
+func (l *recentClients) Count(now time.Time) map[int]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts := make(map[int]int)
+	for id, clients := range l.seen {
+		for client, last := range clients {
+			if now.Sub(last) >= clientTimeout {
+				delete(clients, client)
+			}
+		}
+		if len(clients) == 0 {
+			delete(l.seen, id)
+			continue
+		}
+		counts[id] = len(clients)
+	}
+	return counts
+}
+
+// Samples returns the active clients as samples labeled by ChannelID.
+func (l *recentClients) Samples() []metrics.Sample {
+	var samples []metrics.Sample
+	for id, n := range l.Count(time.Now()) {
+		samples = append(samples, metrics.Sample{Labels: []string{strconv.Itoa(id)}, Value: float64(n)})
+	}
+	return samples
+}
+
+// clientKey identifies the client of the request by its address and user
+// agent, ports are excluded since players reconnect.
+func clientKey(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return host + " " + req.UserAgent()
+}
+
+var proxyRouteRE = regexp.MustCompile(`^/(stream|timeshift|podcast)/(?:(\d+)\b)?`)
+
+// proxyRoute returns the route name of the path and the ChannelID, it is -1
+// if the route is not of a channel.
+func proxyRoute(path string) (string, int) {
+	if path == "/metrics" {
+		return "metrics", -1
+	}
+	matched := proxyRouteRE.FindStringSubmatch(path)
+	if matched == nil {
+		return "other", -1
+	}
+	channelID := -1
+	if matched[1] != "podcast" && matched[2] != "" {
+		channelID, _ = strconv.Atoi(matched[2])
+	}
+	return matched[1], channelID
+}
+
+// countingWriter counts the bytes written and records the status code of a
+// response.
+type countingWriter struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+func (w *countingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+var rankingMetricsOnce sync.Once
+
+// registerRankingMetrics registers the gauges of current channel rankings,
+// refreshed at most once per interval.
+func registerRankingMetrics(interval time.Duration) {
+	rankingMetricsOnce.Do(func() {
+		g := &rankingGauges{interval: interval, fetch: client.ListRankings}
+		metricsRegistry.GaugeFunc("hiradio_channel_ranking",
+			"Current ranking of the channel on Hichannel.",
+			[]string{"channel_id"}, g.Samples)
+	})
+}
+
+// rankingGauges caches the rankings for the gauges.
+type rankingGauges struct {
+	interval time.Duration
+	fetch    func() ([]hiradio.Ranking, error)
+
+	mu      sync.Mutex
+	fetched time.Time
+	list    []hiradio.Ranking
+}
+
+// Samples returns the rankings as samples labeled by ChannelID, the last
+// fetched rankings are kept on errors.
+func (g *rankingGauges) Samples() []metrics.Sample {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now := time.Now(); now.Sub(g.fetched) >= g.interval {
+		g.fetched = now
+		list, err := g.fetch()
+		if err != nil {
+			Warnf("Failed to list rankings: %s", err)
+		} else {
+			g.list = list
+		}
+	}
+	var samples []metrics.Sample
+	for _, r := range g.list {
+		samples = append(samples, metrics.Sample{Labels: []string{strconv.Itoa(r.ID)}, Value: float64(r.Value)})
+	}
+	return samples
+}
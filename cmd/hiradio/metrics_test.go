@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parkghost/hiradio"
+)
+
+func TestRecentClients(t *testing.T) {
+	start := time.Date(2016, 5, 1, 8, 0, 0, 0, time.Local)
+	l := new(recentClients)
+	l.Seen(222, "127.0.0.1 mpv", start)
+	l.Seen(222, "127.0.0.1 vlc", start.Add(30*time.Second))
+	l.Seen(156, "127.0.0.1 mpv", start.Add(10*time.Second))
+
+	if got, want := l.Count(start.Add(40*time.Second)), map[int]int{222: 2, 156: 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := l.Count(start.Add(time.Minute+20*time.Second)), map[int]int{222: 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := l.Count(start.Add(2*time.Minute)), map[int]int{}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestProxyRoute(t *testing.T) {
+	tests := []struct {
+		path      string
+		route     string
+		channelID int
+	}{
+		{"/stream/222.m3u8", "stream", 222},
+		{"/timeshift/156/playlist.m3u8", "timeshift", 156},
+		{"/timeshift/156/1234.ts", "timeshift", 156},
+		{"/podcast/222.xml", "podcast", -1},
+		{"/metrics", "metrics", -1},
+		{"/favicon.ico", "other", -1},
+	}
+	for _, test := range tests {
+		route, channelID := proxyRoute(test.path)
+		if route != test.route || channelID != test.channelID {
+			t.Errorf("%s: got %s %d, want %s %d", test.path, route, channelID, test.route, test.channelID)
+		}
+	}
+}
+
+func TestMetricsRequests(t *testing.T) {
+	d := metricsRequests(hiradio.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Query().Get("id") {
+		case "232":
+			return &http.Response{StatusCode: http.StatusServiceUnavailable}, nil
+		case "156":
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}))
+	for _, id := range []string{"222", "232", "156"} {
+		req, _ := http.NewRequest("GET", "http://hichannel.hinet.net/radio/play.do?id="+id, nil)
+		d.Do(req)
+	}
+
+	var buf bytes.Buffer
+	if err := metricsRegistry.Write(&buf); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	for _, want := range []string{
+		`hiradio_api_requests_total{endpoint="play.do",code="200"} 1`,
+		`hiradio_api_requests_total{endpoint="play.do",code="503"} 1`,
+		`hiradio_api_requests_total{endpoint="play.do",code="none"} 1`,
+		`hiradio_api_errors_total{endpoint="play.do"} 2`,
+		`hiradio_api_request_duration_seconds_count{endpoint="play.do"} 3`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %s in metrics:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+	"github.com/parkghost/hiradio/cmd/internal/monitor"
+)
+
+const (
+	// alertQueueSize is the number of alerts buffered for printing and for
+	// notifications, so that slow hooks or webhooks don't hold the streams.
+	alertQueueSize = 64
+
+	// webhookTimeout is the timeout of posting an alert to the webhook.
+	webhookTimeout = 10 * time.Second
+)
+
+func monitorCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	var th monitor.Thresholds
+	fs.DurationVar(&th.Silence, "silence", 30*time.Second, "Alert on silence longer than the duration, 0 disables silence detection")
+	fs.Float64Var(&th.SilenceLevel, "level", -50, "Audio level in dBFS below which is silence")
+	fs.DurationVar(&th.Stall, "stall", 1*time.Minute, "Alert if no new segments for the duration")
+	fs.IntVar(&th.Failures, "failures", 3, "Alert on the number of consecutive fetch failures")
+	ffmpeg := fs.String("ffmpeg", "ffmpeg", "The ffmpeg command decoding audio for silence detection")
+	hook := fs.String("exec", "", "Command to execute on each alert and recovery, details are passed by HIRADIO_ALERT_* environment variables")
+	webhook := fs.String("webhook", "", "URL to POST each alert and recovery in JSON, within a timeout of 10s")
+	asJSON := fs.Bool("json", format == "json", "Print alerts in JSON format")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio monitor [options] [ChannelID...]
+
+Keep streams of channels (the favorites if no ChannelID given) open and alert
+on prolonged silence, stalled streams or repeated fetch failures, with
+notifications on recovery
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	// parse arguments
+	fs.Parse(args)
+	channelIDs := cfg.GetIntSlice(favoritesKey, nil)
+	if fs.NArg() > 0 {
+		channelIDs = nil
+		for _, arg := range fs.Args() {
+			channelID, err := strconv.Atoi(arg)
+			if err != nil {
+				Fatalf("Failed to parse ChannelID: %s", arg)
+			}
+			channelIDs = append(channelIDs, channelID)
+		}
+	}
+	if len(channelIDs) == 0 {
+		fs.Usage()
+		return
+	}
+	if th.Silence > 0 {
+		if _, err := exec.LookPath(*ffmpeg); err != nil {
+			Warnf("Silence detection is disabled: %s", err)
+			th.Silence = 0
+		}
+	}
+
+	alerts := make(chan monitor.Alert, alertQueueSize)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, id := range channelIDs {
+		c := &monitor.Channel{ID: id, Thresholds: th}
+		if info, err := client.GetChannelInfo(id); err != nil {
+			Warnf("Failed to get channel title: %s", err)
+		} else {
+			c.Title = info.Title
+		}
+		m := &streamMonitor{channel: c, ffmpeg: *ffmpeg, alerts: alerts}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Run(stop)
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		<-quit
+		close(stop)
+		wg.Wait()
+		close(done)
+	}()
+
+	var notifications chan monitor.Alert
+	if *hook != "" || *webhook != "" {
+		notifications = make(chan monitor.Alert, alertQueueSize)
+		go notifyAlerts(notifications, *hook, *webhook)
+	}
+
+	fmt.Fprintf(os.Stderr, "Monitoring channels %v, press ctrl-c to exit\n", channelIDs)
+	for {
+		var a monitor.Alert
+		select {
+		case a = <-alerts:
+		case <-done:
+			return
+		}
+		printAlert(a, *asJSON)
+		if notifications != nil {
+			select {
+			case notifications <- a:
+			default:
+				Warnf("Dropped notification of channel %d: too many pending alerts", a.ChannelID)
+			}
+		}
+	}
+}
+
+// notifyAlerts executes the hook and posts to the webhook for each alert in
+// the queue, in the order of alerts.
+func notifyAlerts(queue <-chan monitor.Alert, hook, webhook string) {
+	webhookClient := &http.Client{Timeout: webhookTimeout}
+	for a := range queue {
+		if hook != "" {
+			if err := runHook(hook, alertEnv(a)); err != nil {
+				Warnf("Failed to execute hook: %s", err)
+			}
+		}
+		if webhook != "" {
+			if err := postAlert(webhookClient, webhook, a); err != nil {
+				Warnf("Failed to post alert: %s", err)
+			}
+		}
+	}
+}
+
+// streamMonitor keeps the stream of a channel open and sends alerts of it.
+type streamMonitor struct {
+	channel *monitor.Channel
+	ffmpeg  string
+	alerts  chan<- monitor.Alert
+}
+
+// Run monitors the stream until stop is closed.
+func (m *streamMonitor) Run(stop <-chan struct{}) {
+	id := m.channel.ID
+	m.channel.Start(time.Now())
+	go func() {
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				m.send(m.channel.Check(now), stop)
+			}
+		}
+	}()
+
+	s := newStream(id)
+	for {
+		err := s.Run(stop, func(seg hls.Segment, data []byte) error {
+			level := math.NaN()
+			if m.channel.Thresholds.Silence > 0 {
+				l, err := decodeLevel(m.ffmpeg, data)
+				if err != nil {
+					Warnf("Failed to decode segment of channel %d: %s", id, err)
+				} else {
+					level = l
+				}
+			}
+			m.send(m.channel.Segment(time.Now(), seg.Duration, level), stop)
+			return nil
+		})
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if err == nil {
+			err = fmt.Errorf("stream of channel %d is ended", id)
+		}
+		m.send(m.channel.Fail(time.Now(), err), stop)
+		s.Reset()
+		select {
+		case <-stop:
+			return
+		case <-time.After(recordRetryDelay):
+		}
+	}
+}
+
+func (m *streamMonitor) send(alerts []monitor.Alert, stop <-chan struct{}) {
+	for _, a := range alerts {
+		select {
+		case m.alerts <- a:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// decodeLevel decodes the media segment by ffmpeg and returns the audio level
+// in dBFS.
+func decodeLevel(ffmpeg string, data []byte) (float64, error) {
+	cmd := exec.Command(ffmpeg, "-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0", "-vn", "-f", "s16le", "-ac", "1", "-ar", "8000", "pipe:1")
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	pcm, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return monitor.Level(pcm), nil
+}
+
+func printAlert(a monitor.Alert, asJSON bool) {
+	if asJSON {
+		data, _ := json.Marshal(a)
+		fmt.Println(string(data))
+		return
+	}
+	state := "ALERT"
+	if a.Recovered {
+		state = "RECOVERED"
+	}
+	fmt.Printf("%s  %4d  %s  %-9s  %-7s  %s\n",
+		a.Time.Format("2006-01-02 15:04:05"),
+		a.ChannelID,
+		a.Title,
+		state,
+		a.Condition,
+		a.Message)
+}
+
+// alertEnv returns the alert as environment variables for the hook command,
+// prefixed with HIRADIO_ALERT_ like watch events.
+func alertEnv(a monitor.Alert) []string {
+	state := "alert"
+	if a.Recovered {
+		state = "recovered"
+	}
+	return []string{
+		"HIRADIO_ALERT_TIME=" + a.Time.Format(time.RFC3339),
+		"HIRADIO_ALERT_CHANNEL_ID=" + strconv.Itoa(a.ChannelID),
+		"HIRADIO_ALERT_CHANNEL_TITLE=" + a.Title,
+		"HIRADIO_ALERT_CONDITION=" + string(a.Condition),
+		"HIRADIO_ALERT_STATE=" + state,
+		"HIRADIO_ALERT_SINCE=" + a.Since.Format(time.RFC3339),
+		"HIRADIO_ALERT_MESSAGE=" + a.Message,
+	}
+}
+
+// postAlert posts the alert in JSON to the webhook URL.
+func postAlert(c *http.Client, url string, a monitor.Alert) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("POST %s: %s", url, resp.Status)
+	}
+	return nil
+}
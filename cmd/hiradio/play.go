@@ -4,39 +4,22 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"regexp"
 	"strconv"
-
-	"github.com/parkghost/hiradio"
-	"github.com/parkghost/hiradio/cmd/internal/config"
-)
-
-const (
-	channelIDKey = "channelID"
-	playerKey    = "player"
-	proxyPortKey = "proxyPort"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func playCmd(args []string) {
-	// load config from file
-	cfgPath, err := configPath("play.json")
-	if err != nil {
-		Warnf("Failed to load configuration: %s", err)
-	}
-	cfg, err := loadConfig(cfgPath)
-	if err != nil {
-		Warnf("Failed to load configuration: %s", err)
-	}
-
 	// flag settings
 	fs := flag.NewFlagSet("play", flag.ExitOnError)
-	app := fs.String("player", cfg.GetString(playerKey, ""), "The player which supports HTTP Live Streaming")
-	port := fs.Int("port", cfg.GetInt(proxyPortKey, 1077), "Port for the proxy server")
-	verbose := fs.Bool("verbose", false, "Print output from the player")
+	opts := newPlayOptions(fs)
 	fs.Usage = func() {
 		fmt.Fprintln(os.Stderr, `usage: hiradio play [options] [ChannelID]
 
@@ -44,6 +27,7 @@ Play radio on player
 
 The options are:`)
 		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nThe player presets are:\n%s\n", playerUsage(opts.presets))
 		os.Exit(1)
 	}
 
@@ -53,7 +37,7 @@ The options are:`)
 		fs.Usage()
 		return
 	}
-	channelID, err := getChannelID(fs.Args(), cfg)
+	channelID, err := getChannelID(fs.Args())
 	if err != nil {
 		if err == errNotFound {
 			fs.Usage()
@@ -63,18 +47,87 @@ The options are:`)
 	}
 
 	// save current config
-	if cfgPath != "" {
-		cfg.Set(playerKey, *app)
-		cfg.Set(proxyPortKey, *port)
-		cfg.Set(channelIDKey, channelID)
-		if err := config.SaveTo(cfgPath, cfg); err != nil {
-			Warnf("Failed to save configuration: %s", err)
-		}
+	saveFlags(fs, map[string]string{
+		"player":         playerKey,
+		"port":           proxyPortKey,
+		"volume-cmd":     volumeCmdKey,
+		"volume-get-cmd": volumeGetCmdKey,
+	})
+	cfg.Set(channelIDKey, channelID)
+	saveSettings()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	play(channelID, opts, quit)
+}
+
+// playOptions represents the options of playing radio.
+type playOptions struct {
+	player       string
+	presets      map[string]string
+	port         int
+	verbose      bool
+	status       bool
+	notify       bool
+	refresh      time.Duration
+	restart      string
+	maxRestarts  int
+	restartDelay time.Duration
+	killTimeout  time.Duration
+	sleep        time.Duration
+	fade         time.Duration
+	volumeCmd    string
+	volumeGetCmd string
+	timeshift    time.Duration
+	dump         string
+}
+
+// newPlayOptions defines the flags of playing radio in fs.
+func newPlayOptions(fs *flag.FlagSet) *playOptions {
+	o := &playOptions{
+		presets: playerPresets(cfg.GetStringMap(playersKey, nil)),
+	}
+	fs.StringVar(&o.player, "player", cfg.GetString(playerKey, ""), "The player which supports HTTP Live Streaming, a preset name or a command template (detect installed player if empty)")
+	fs.IntVar(&o.port, "port", cfg.GetInt(proxyPortKey, defaultProxyPort), "Port for the proxy server")
+	fs.BoolVar(&o.verbose, "verbose", false, "Print output from the player")
+	fs.BoolVar(&o.status, "status", true, "Display the current and next program")
+	fs.BoolVar(&o.notify, "notify", false, "Send desktop notifications when the program changes")
+	fs.DurationVar(&o.refresh, "refresh", 1*time.Minute, "Interval for refreshing the program list")
+	fs.StringVar(&o.restart, "restart", string(restartOnFailure), "Restart policy of the player: no, on-failure or always")
+	fs.IntVar(&o.maxRestarts, "max-restarts", 0, "Maximum number of player restarts, 0 means unlimited")
+	fs.DurationVar(&o.restartDelay, "restart-delay", 1*time.Second, "Initial delay before restarting the player, doubled on each restart")
+	fs.DurationVar(&o.killTimeout, "kill-timeout", 5*time.Second, "Time to wait for the player to exit before killing it")
+	fs.DurationVar(&o.sleep, "sleep", 0, "Stop playback after the duration, e.g. 45m")
+	fs.DurationVar(&o.fade, "fade", 0, "Fade out the volume during the end of the sleep duration, requires -volume-cmd")
+	fs.StringVar(&o.volumeCmd, "volume-cmd", cfg.GetString(volumeCmdKey, ""), "Command to set the volume for fading, {volume} is replaced with 0-100")
+	fs.StringVar(&o.volumeGetCmd, "volume-get-cmd", cfg.GetString(volumeGetCmdKey, ""), "Command printing the current volume 0-100, which is faded from and restored after playback (the volume is not restored if empty)")
+	fs.DurationVar(&o.timeshift, "timeshift", 0, "Buffer the last duration of the broadcast to pause and rewind it by \"hiradio timeshift\", e.g. 30m")
+	fs.StringVar(&o.dump, "dump", "", "Save the stream into the file, tagged with the channel and programs when playback stops")
+	return o
+}
+
+// play plays the channel until quit is received, the sleep timer is expired
+// or the player exited. The exit status of the player is forwarded.
+func play(channelID int, o *playOptions, quit <-chan os.Signal) {
+	policy, err := parseRestartPolicy(o.restart)
+	if err != nil {
+		Fatal(err)
+	}
+	if o.restartDelay <= 0 {
+		Fatal("Restart delay must be positive")
+	}
+	if o.fade > 0 && o.volumeCmd == "" {
+		Fatal("Fading requires -volume-cmd")
+	}
+	if o.fade > o.sleep {
+		o.fade = o.sleep
 	}
 
 	// run proxy server
-	proxyServer := proxy{
-		address: ":" + strconv.Itoa(*port),
+	proxyServer := &proxy{
+		address:    ":" + strconv.Itoa(o.port),
+		timeshifts: &timeshifts{length: o.timeshift},
+		podcasts:   &podcastServer{},
 	}
 	go func() {
 		if err := proxyServer.Run(); err != nil {
@@ -82,34 +135,144 @@ The options are:`)
 		}
 	}()
 
+	// save stream
+	var dump *streamDump
+	if o.dump != "" {
+		if dump, err = startDump(channelID, o.dump); err != nil {
+			Fatalf("Failed to save stream: %s", err)
+		}
+	}
+
 	// run audio player
-	quit := make(chan os.Signal)
-	playlist := fmt.Sprintf("http://localhost:%d/stream/%d.m3u8", *port, channelID)
-	if *app == "" {
+	exited := make(chan error, 1)
+	playlist := fmt.Sprintf("http://localhost:%d/stream/%d.m3u8", o.port, channelID)
+	if o.timeshift > 0 {
+		playlist = fmt.Sprintf("http://localhost:%d/timeshift/%d/playlist.m3u8", o.port, channelID)
+	}
+	playerName := o.player
+	if playerName == "" {
+		if name, found := detectPlayer(o.presets, exec.LookPath); found {
+			playerName = name
+		}
+	}
+	var p *player
+	if playerName == "" {
 		fmt.Printf("Open URL with player: %s\n", playlist)
 	} else {
-		p := player{
-			app:     *app,
-			url:     playlist,
-			verbose: *verbose,
+		p = &player{
+			args:         playerArgs(playerName, o.presets, playlist, channelID),
+			verbose:      o.verbose,
+			restart:      policy,
+			maxRestarts:  o.maxRestarts,
+			restartDelay: o.restartDelay,
+			killTimeout:  o.killTimeout,
 		}
 		go func() {
-			if err := p.Run(); err != nil {
-				Fatal(err)
-			}
-			close(quit)
+			exited <- p.Run()
 		}()
 	}
 
-	fmt.Print("Press ctrl-c to exit")
-	signal.Notify(quit, os.Kill, os.Interrupt)
-	<-quit
+	if !o.status {
+		fmt.Print("Press ctrl-c to exit")
+	}
+	stop := make(chan struct{})
+	ps := &playStatus{
+		channelID: channelID,
+		fetch:     client.GetChannelInfo,
+		start:     time.Now(),
+	}
+	if o.notify {
+		ps.notifier = &dbusNotifier{appName: "hiradio", timeout: 5 * time.Second}
+	}
+	out := ioutil.Discard
+	if o.status {
+		out = os.Stdout
+	}
+	statusDone := make(chan struct{})
+	go func() {
+		ps.Run(out, o.refresh, stop)
+		close(statusDone)
+	}()
+
+	// sleep timer
+	var sleep <-chan time.Time
+	if o.sleep > 0 {
+		sleep = time.After(o.sleep - o.fade)
+	}
+
+	var playErr error
+	running := p != nil
+	// volume before fading, -1 if unknown
+	volume := -1
+	select {
+	case <-quit:
+	case <-sleep:
+		if o.fade > 0 && running {
+			level := 100
+			if o.volumeGetCmd != "" {
+				if v, err := getVolume(o.volumeGetCmd); err != nil {
+					Warnf("Failed to get volume: %s", err)
+				} else {
+					level, volume = v, v
+				}
+			}
+			fadeOut(o.volumeCmd, level, o.fade, quit)
+		}
+	case playErr = <-exited:
+		running = false
+	}
+	close(stop)
+	<-statusDone
+	if running {
+		p.Stop()
+		<-exited
+	}
+	proxyServer.Close()
+	if dump != nil {
+		if err := dump.Close(); err != nil {
+			Warnf("Failed to save stream: %s", err)
+		}
+	}
+	recordSession(ps.Session(time.Now()))
+	if volume != -1 {
+		if err := setVolume(o.volumeCmd, volume); err != nil {
+			Warnf("Failed to restore volume: %s", err)
+		}
+	}
 	println()
+
+	// forward exit status of the player
+	if playErr != nil {
+		Warnf("Player exited: %s", playErr)
+		os.Exit(exitCode(playErr))
+	}
+}
+
+// playerArgs returns the command line of the player.
+func playerArgs(name string, presets map[string]string, playlist string, channelID int) []string {
+	tmpl, err := resolvePlayer(name, presets)
+	if err != nil {
+		Fatalf("Failed to parse player %q: %s", name, err)
+	}
+	if len(tmpl) == 0 {
+		Fatalf("Empty player command: %q", name)
+	}
+
+	var title string
+	if strings.Contains(strings.Join(tmpl, " "), "{title}") {
+		info, err := client.GetChannelInfo(channelID)
+		if err != nil {
+			Warnf("Failed to get channel title: %s", err)
+		} else {
+			title = info.Title
+		}
+	}
+	return expandArgs(tmpl, playlist, title, channelID)
 }
 
 var errNotFound = errors.New("key not found")
 
-func getChannelID(args []string, cfg *config.Config) (int, error) {
+func getChannelID(args []string) (int, error) {
 	if len(args) > 0 {
 		channelID, err := strconv.Atoi(args[0])
 		if err != nil {
@@ -126,16 +289,50 @@ func getChannelID(args []string, cfg *config.Config) (int, error) {
 }
 
 type proxy struct {
-	address string
+	address    string
+	timeshifts *timeshifts
+	podcasts   *podcastServer
 }
 
 func (p *proxy) Run() error {
+	if d := cfg.GetDuration(metricsRankingsKey, 0); d > 0 {
+		registerRankingMetrics(d)
+	}
 	return http.ListenAndServe(p.address, p)
 }
 
+// Close releases the resources of the proxy.
+func (p *proxy) Close() {
+	p.timeshifts.Close()
+}
+
 var routeRE = regexp.MustCompile(`/stream/(\d+).m3u8`)
 
 func (p *proxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	route, channelID := proxyRoute(req.URL.Path)
+	if channelID != -1 {
+		proxyClients.Seen(channelID, clientKey(req), time.Now())
+	}
+	cw := &countingWriter{ResponseWriter: rw, status: http.StatusOK}
+	p.serve(cw, req)
+	proxyRequests.Inc(route, strconv.Itoa(cw.status))
+	proxyBytes.Add(float64(cw.n), route)
+}
+
+func (p *proxy) serve(rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/metrics" {
+		metricsRegistry.ServeHTTP(rw, req)
+		return
+	}
+	if strings.HasPrefix(req.URL.Path, "/timeshift/") {
+		p.timeshifts.ServeHTTP(rw, req)
+		return
+	}
+	if strings.HasPrefix(req.URL.Path, "/podcast/") {
+		p.podcasts.ServeHTTP(rw, req)
+		return
+	}
+
 	matched := routeRE.FindStringSubmatch(req.RequestURI)
 	if matched == nil {
 		http.NotFound(rw, req)
@@ -143,32 +340,55 @@ func (p *proxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 	channelID, _ := strconv.Atoi(matched[1])
 
-	pl, err := hiradio.GetPlaylist(channelID)
+	pl, err := client.GetPlaylist(channelID)
 	if err != nil {
 		Warnf("Failed to get playlist: %s", err)
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	upstreamRefreshes.Inc(strconv.Itoa(channelID))
 	http.Redirect(rw, req, pl.URL, http.StatusTemporaryRedirect)
 }
 
-type player struct {
-	app     string
-	url     string
-	verbose bool
+// fadeOutSteps is the number of volume changes while fading out.
+const fadeOutSteps = 20
 
-	cmd *exec.Cmd
+// fadeOut decreases the volume from level to zero in duration d by executing
+// command.
+func fadeOut(command string, level int, d time.Duration, quit <-chan os.Signal) {
+	interval := d / fadeOutSteps
+	for i := 1; i <= fadeOutSteps; i++ {
+		select {
+		case <-time.After(interval):
+		case <-quit:
+			return
+		}
+		if err := setVolume(command, level-level*i/fadeOutSteps); err != nil {
+			Warnf("Failed to set volume: %s", err)
+			return
+		}
+	}
 }
 
-func (p *player) Run() error {
-	p.cmd = exec.Command(p.app, p.url)
-	if p.verbose {
-		p.cmd.Stdout = os.Stdout
-		p.cmd.Stderr = os.Stderr
-		fmt.Println()
+// setVolume executes command with {volume} replaced with volume.
+func setVolume(command string, volume int) error {
+	return runHook(strings.Replace(command, "{volume}", strconv.Itoa(volume), -1), nil)
+}
+
+// getVolume executes command and returns the volume 0-100 it printed.
+func getVolume(command string) (int, error) {
+	out, err := shellCommand(command).Output()
+	if err != nil {
+		return 0, err
 	}
-	if err := p.cmd.Run(); err != nil {
-		return err
+	return parseVolume(string(out))
+}
+
+// parseVolume parses a volume 0-100 with an optional percent sign.
+func parseVolume(s string) (int, error) {
+	v, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	if err != nil || v < 0 || v > 100 {
+		return 0, fmt.Errorf("invalid volume %q", strings.TrimSpace(s))
 	}
-	return nil
+	return v, nil
 }
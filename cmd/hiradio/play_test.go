@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestParseVolume(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"35\n", 35},
+		{" 80%\n", 80},
+		{"0", 0},
+		{"100%", 100},
+	}
+	for _, test := range tests {
+		got, err := parseVolume(test.s)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if got != test.want {
+			t.Fatalf("got %d, want %d", got, test.want)
+		}
+	}
+	for _, s := range []string{"", "loud", "101", "-1"} {
+		if _, err := parseVolume(s); err == nil {
+			t.Fatalf("expected error on volume %q", s)
+		}
+	}
+}
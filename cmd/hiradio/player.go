@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// restartPolicy decides whether the player should be restarted after exit.
+type restartPolicy string
+
+const (
+	restartNo        restartPolicy = "no"
+	restartOnFailure restartPolicy = "on-failure"
+	restartAlways    restartPolicy = "always"
+)
+
+func parseRestartPolicy(s string) (restartPolicy, error) {
+	switch rp := restartPolicy(s); rp {
+	case restartNo, restartOnFailure, restartAlways:
+		return rp, nil
+	}
+	return "", fmt.Errorf("unknown restart policy %q", s)
+}
+
+const (
+	// maxRestartDelay is the upper bound of backoff between restarts.
+	maxRestartDelay = 1 * time.Minute
+	// stableRunTime resets the backoff if the player ran longer than it.
+	stableRunTime = 1 * time.Minute
+)
+
+// player supervises the player process.
+type player struct {
+	args    []string
+	verbose bool
+
+	restart      restartPolicy
+	maxRestarts  int // unlimited if zero
+	restartDelay time.Duration
+	killTimeout  time.Duration
+
+	initOnce sync.Once
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// Run starts the player and restarts it according to the restart policy. It
+// returns the last exit error of the player.
+func (p *player) Run() error {
+	p.init()
+
+	delay := p.restartDelay
+	restarts := 0
+	for {
+		started := time.Now()
+		err := p.runOnce()
+		select {
+		case <-p.stop:
+			return err
+		default:
+		}
+
+		if p.restart == restartNo || (err == nil && p.restart != restartAlways) {
+			return err
+		}
+		if p.maxRestarts > 0 && restarts >= p.maxRestarts {
+			return err
+		}
+
+		if time.Since(started) > stableRunTime {
+			delay = p.restartDelay
+		}
+		if err != nil {
+			Warnf("Player exited: %s, restart in %s", err, delay)
+		} else {
+			Warnf("Player exited, restart in %s", delay)
+		}
+		select {
+		case <-time.After(delay):
+		case <-p.stop:
+			return err
+		}
+		restarts++
+		delay *= 2
+		if delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+}
+
+// Stop terminates the player gracefully. It sends SIGTERM to the player and
+// kills it if it does not exit within killTimeout.
+func (p *player) Stop() {
+	p.init()
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+func (p *player) init() {
+	p.initOnce.Do(func() {
+		p.stop = make(chan struct{})
+	})
+}
+
+func (p *player) runOnce() error {
+	cmd := exec.Command(p.args[0], p.args[1:]...)
+	if p.verbose {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		fmt.Println()
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		return err
+	case <-p.stop:
+	}
+
+	// SIGTERM is not supported on windows, kill it directly
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		cmd.Process.Kill()
+		return <-exited
+	}
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(p.killTimeout):
+		Warnf("Player did not exit in %s, killing it", p.killTimeout)
+		cmd.Process.Kill()
+		return <-exited
+	}
+}
+
+// exitCode returns the exit status of the player from err.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if ee, ok := err.(*exec.ExitError); ok {
+		if code := ee.ExitCode(); code > 0 {
+			return code
+		}
+	}
+	return 1
+}
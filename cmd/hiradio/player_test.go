@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPlayerRestart(t *testing.T) {
+	app, err := exec.LookPath("false")
+	if err != nil {
+		t.Skip("false not found")
+	}
+
+	p := &player{
+		args:         []string{app, "http://localhost:1077/stream/222.m3u8"},
+		restart:      restartOnFailure,
+		maxRestarts:  2,
+		restartDelay: time.Millisecond,
+	}
+	start := time.Now()
+	err = p.Run()
+	if err == nil {
+		t.Fatal("expected exit error from player")
+	}
+	if code := exitCode(err); code != 1 {
+		t.Fatalf("got exit code %d, want 1", code)
+	}
+	// 1ms + 2ms backoff between three runs
+	if elapsed := time.Since(start); elapsed < 3*time.Millisecond {
+		t.Fatalf("player was not restarted with backoff, elapsed %s", elapsed)
+	}
+}
+
+func TestPlayerStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available on windows")
+	}
+	app, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+
+	p := &player{
+		args:        []string{app, "10"},
+		restart:     restartAlways,
+		killTimeout: time.Second,
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- p.Run()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	p.Stop()
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("player was not stopped")
+	}
+}
+
+func TestParseRestartPolicy(t *testing.T) {
+	for _, s := range []string{"no", "on-failure", "always"} {
+		if _, err := parseRestartPolicy(s); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+	if _, err := parseRestartPolicy("sometimes"); err == nil {
+		t.Fatal("expected error on unknown restart policy")
+	}
+}
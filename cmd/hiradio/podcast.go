@@ -0,0 +1,431 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/parkghost/hiradio"
+	"github.com/parkghost/hiradio/cmd/internal/archive"
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+	"github.com/parkghost/hiradio/cmd/internal/podcast"
+	"github.com/parkghost/hiradio/cmd/internal/recording"
+)
+
+const (
+	recordingsDirName = "recordings"
+
+	// channelImageBaseURL is the location of channel logos, the API only
+	// returns their file names.
+	channelImageBaseURL = "http://hichannel.hinet.net/upload/radio/channel/"
+)
+
+func podcastCmd(args []string) {
+	if len(args) > 0 && args[0] == "feeds" {
+		podcastFeeds(args[1:])
+		return
+	}
+
+	// flag settings
+	fs := flag.NewFlagSet("podcast", flag.ExitOnError)
+	port := fs.Int("port", cfg.GetInt(proxyPortKey, defaultProxyPort), "Port for the proxy server serving the feeds")
+	refresh := fs.Duration("refresh", 1*time.Minute, "Interval for checking the program list")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio podcast [options] ChannelID [PROGRAM...]
+       hiradio podcast feeds
+
+Record programs whose name contains one of PROGRAM (all programs if none is
+given) and serve them as podcast feeds by the proxy server, or list the URLs
+of feeds
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return
+	}
+	channelID, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		Fatalf("Failed to parse ChannelID: %s", fs.Arg(0))
+	}
+	programs := fs.Args()[1:]
+	dir := recordingsDir()
+	policy, err := recordingsPolicy()
+	if err != nil {
+		Fatal(err)
+	}
+
+	// serve feeds, they are also served by the proxy of other running commands
+	proxyServer := &proxy{
+		address:    ":" + strconv.Itoa(*port),
+		timeshifts: &timeshifts{},
+		podcasts:   &podcastServer{dir},
+	}
+	go func() {
+		if err := proxyServer.Run(); err != nil {
+			Warnf("Failed to start proxy: %s", err)
+		}
+	}()
+
+	stop := make(chan struct{})
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		<-quit
+		close(stop)
+	}()
+	fmt.Printf("Recording programs of channel %d into %s, press ctrl-c to exit\n", channelID, dir)
+	capturePrograms(channelID, programs, dir, policy, *refresh, stop)
+}
+
+// recordingsDir returns the directory of recorded programs.
+func recordingsDir() string {
+	dir, err := recordingsPath()
+	if err != nil {
+		Fatalf("Failed to locate recordings: %s", err)
+	}
+	return dir
+}
+
+// recordingsPath returns the directory of recorded programs, or an error if
+// the default directory cannot be created.
+func recordingsPath() (string, error) {
+	if dir := cfg.GetString(recordingsDirKey, ""); dir != "" {
+		return dir, nil
+	}
+	return configPath(recordingsDirName)
+}
+
+// matchProgram reports whether the program name contains one of queries,
+// ignoring case. All programs are matched if queries is empty.
+func matchProgram(name string, queries []string) bool {
+	if len(queries) == 0 {
+		return true
+	}
+	name = strings.ToLower(name)
+	for _, q := range queries {
+		if strings.Contains(name, strings.ToLower(q)) {
+			return true
+		}
+	}
+	return false
+}
+
+// capturePrograms records the matched programs of the channel when they are on
+// air until stop is closed. Recordings are pruned by the policy after each
+// program.
+func capturePrograms(channelID int, queries []string, dir string, policy recording.Policy, refresh time.Duration, stop <-chan struct{}) {
+	var image string
+	imageResolved := false
+	for {
+		wait := refresh
+		info, err := client.GetChannelInfo(channelID)
+		if err != nil {
+			Warnf("Failed to fetch program list: %s", err)
+		} else {
+			if !imageResolved {
+				image, imageResolved = channelImage(channelID, info), true
+			}
+			now := time.Now()
+			for _, p := range matchedPrograms(now, info.List, queries) {
+				if !p.Start.After(now) && now.Before(p.Stop) {
+					r := newRecording(channelID, info, image, p)
+					if err := captureProgram(r, dir, p.Stop, stop); err != nil {
+						Warnf("Failed to record %s: %s", p.Name, err)
+					}
+					if !policy.IsZero() {
+						if err := pruneRecordings(dir, policy, false); err != nil {
+							Warnf("Failed to prune recordings: %s", err)
+						}
+					}
+					wait = 0
+					break
+				}
+				if d := p.Start.Sub(now); d > 0 && d < wait {
+					wait = d
+				}
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// matchedPrograms returns the matched programs of the schedule on the day
+// before now and the day of now, since a program of the day before may pass
+// midnight and still be on air.
+func matchedPrograms(now time.Time, list []hiradio.Program, queries []string) []archive.Program {
+	var matched []archive.Program
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+		for _, p := range archive.ResolvePrograms(day, list) {
+			if matchProgram(p.Name, queries) {
+				matched = append(matched, p)
+			}
+		}
+	}
+	return matched
+}
+
+// newRecording returns the recording of the program with channel metadata.
+func newRecording(channelID int, info *hiradio.ChannelInfo, image string, p archive.Program) recording.Recording {
+	return recording.Recording{
+		ChannelID:    channelID,
+		ChannelTitle: info.Title,
+		ChannelDesc:  info.Desc,
+		ChannelImage: image,
+		Program:      p.Name,
+		Start:        time.Now(),
+	}
+}
+
+// channelImage returns the URL of the channel logo.
+func channelImage(channelID int, info *hiradio.ChannelInfo) string {
+	if channels, err := client.ListChannels(); err == nil {
+		for _, c := range channels {
+			if c.ID == channelID && c.Image != "" {
+				return imageURL(c.Image)
+			}
+		}
+	}
+	return imageURL(info.Image)
+}
+
+// imageURL resolves the file name of a channel logo returned by the API, e.g.
+// 14abcde694d00000b2fc.jpg, to its URL. Absolute URLs are returned unchanged.
+func imageURL(name string) string {
+	if name == "" || strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		return name
+	}
+	return channelImageBaseURL + url.PathEscape(name)
+}
+
+// captureProgram records the stream into the recording until the time stopAt
+// or stop is closed.
+func captureProgram(r recording.Recording, dir string, stopAt time.Time, stop <-chan struct{}) error {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-stop:
+		case <-time.After(stopAt.Sub(time.Now())):
+		}
+		close(done)
+	}()
+
+	fmt.Printf("Recording %s %s\n", r.Program, r.Start.Format("2006-01-02 15:04"))
+	var f *os.File
+	var writeErr error
+	recordStream(r.ChannelID, newStream(r.ChannelID), done, func(seg hls.Segment, data []byte) error {
+		if f == nil {
+			r.File = recording.FileName(r.ChannelID, r.Program, r.Start, path.Ext(seg.URI))
+			if writeErr = os.MkdirAll(filepath.Dir(r.Path(dir)), 0755); writeErr != nil {
+				return writeErr
+			}
+			if f, writeErr = os.Create(r.Path(dir)); writeErr != nil {
+				return writeErr
+			}
+		}
+		_, writeErr = f.Write(data)
+		return writeErr
+	})
+	<-done
+	if f == nil {
+		return writeErr
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	r.Stop = time.Now()
+	if err := writeTags(r.Path(dir), r.ChannelID, r.Start, r.Stop); err != nil {
+		Warnf("Failed to tag %s: %s", r.File, err)
+	}
+	if err := recording.Save(dir, r); err != nil {
+		return err
+	}
+	fmt.Printf("Recorded %s\n", r.File)
+	return writeErr
+}
+
+func podcastFeeds(args []string) {
+	fs := flag.NewFlagSet("podcast feeds", flag.ExitOnError)
+	port := fs.Int("port", cfg.GetInt(proxyPortKey, defaultProxyPort), "Port of the proxy server")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio podcast feeds [options]
+
+List the URLs of podcast feeds
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return
+	}
+
+	recordings, err := recording.Load(recordingsDir())
+	if err != nil {
+		Fatalf("Failed to load recordings: %s", err)
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	base := fmt.Sprintf("http://%s:%d", host, *port)
+
+	feeds := podcastFeedList(recordings)
+	if format == "json" {
+		for i := range feeds {
+			feeds[i].URL = base + feeds[i].URL
+		}
+		printJSON(feeds)
+		return
+	}
+	for _, f := range feeds {
+		wTitle := 40 - stringWidth(f.Title) + len([]rune(f.Title))
+		fmt.Printf("%-*s  %3d  %s%s\n", wTitle, f.Title, f.Episodes, base, f.URL)
+	}
+}
+
+// podcastFeedInfo is a feed in the feed list.
+type podcastFeedInfo struct {
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Episodes int    `json:"episodes"`
+}
+
+// podcastFeedList returns the channel and program feeds of recordings.
+func podcastFeedList(recordings []recording.Recording) []podcastFeedInfo {
+	index := make(map[string]int)
+	feeds := []podcastFeedInfo{}
+	add := func(title, u string) {
+		if i, found := index[u]; found {
+			feeds[i].Episodes++
+			return
+		}
+		index[u] = len(feeds)
+		feeds = append(feeds, podcastFeedInfo{title, u, 1})
+	}
+	for _, r := range recordings {
+		add(r.ChannelTitle, fmt.Sprintf("/podcast/%d.xml", r.ChannelID))
+		add(r.Program+" - "+r.ChannelTitle, fmt.Sprintf("/podcast/%d/%s.xml", r.ChannelID, url.PathEscape(r.Program)))
+	}
+	sort.Sort(feedsByURL(feeds))
+	return feeds
+}
+
+type feedsByURL []podcastFeedInfo
+
+func (s feedsByURL) Len() int           { return len(s) }
+func (s feedsByURL) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s feedsByURL) Less(i, j int) bool { return s[i].URL < s[j].URL }
+
+// podcastServer serves podcast feeds of the recordings in dir, or in the
+// configured directory if dir is empty.
+type podcastServer struct {
+	dir string
+}
+
+var podcastRouteRE = regexp.MustCompile(`^/podcast/(?:(\d+)\.xml|(\d+)/([^/]+)\.xml|files/(.+))$`)
+
+func (s *podcastServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	dir := s.dir
+	if dir == "" {
+		var err error
+		if dir, err = recordingsPath(); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.URL.Path == "/podcast/" {
+		recordings, err := recording.Load(dir)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(rw, podcastFeedList(recordings))
+		return
+	}
+
+	// match the escaped path, program names may contain an escaped slash
+	matched := podcastRouteRE.FindStringSubmatch(req.URL.EscapedPath())
+	if matched == nil {
+		http.NotFound(rw, req)
+		return
+	}
+	if matched[4] != "" {
+		name, err := url.PathUnescape(matched[4])
+		if err != nil {
+			http.NotFound(rw, req)
+			return
+		}
+		name = path.Clean("/" + name)
+		rw.Header().Set("Content-Type", segmentType(name))
+		http.ServeFile(rw, req, filepath.Join(dir, filepath.FromSlash(name)))
+		return
+	}
+
+	recordings, err := recording.Load(dir)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	channelID, program := matched[1], ""
+	if channelID == "" {
+		channelID = matched[2]
+		if program, err = url.PathUnescape(matched[3]); err != nil {
+			http.NotFound(rw, req)
+			return
+		}
+	}
+	id, _ := strconv.Atoi(channelID)
+	var list []recording.Recording
+	for _, r := range recordings {
+		if r.ChannelID == id && (program == "" || r.Program == program) {
+			list = append(list, r)
+		}
+	}
+	if len(list) == 0 {
+		http.NotFound(rw, req)
+		return
+	}
+
+	base := "http://" + req.Host
+	latest := list[0]
+	feed := podcast.Feed{
+		Title:       latest.ChannelTitle,
+		Link:        base + req.URL.EscapedPath(),
+		Description: latest.ChannelDesc,
+		Image:       imageURL(latest.ChannelImage),
+		Author:      latest.ChannelTitle,
+	}
+	if program != "" {
+		feed.Title = program + " - " + latest.ChannelTitle
+	}
+	fileURL := func(r recording.Recording) string {
+		return base + "/podcast/files/" + (&url.URL{Path: r.File}).EscapedPath()
+	}
+	rw.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	if err := podcast.Write(rw, feed, list, fileURL, segmentType); err != nil {
+		Warnf("Failed to write feed: %s", err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parkghost/hiradio"
+	"github.com/parkghost/hiradio/cmd/internal/archive"
+	"github.com/parkghost/hiradio/cmd/internal/recording"
+)
+
+func TestMatchProgram(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		queries []string
+		want    bool
+	}{
+		{"週日 HIT DJ", nil, true},
+		{"週日 HIT DJ", []string{"hit dj"}, true},
+		{"週日 HIT DJ", []string{"早安", "DJ"}, true},
+		{"HITO唱片行", []string{"DJ"}, false},
+	} {
+		if got := matchProgram(c.name, c.queries); got != c.want {
+			t.Errorf("matchProgram(%q, %q) got %v, want %v", c.name, c.queries, got, c.want)
+		}
+	}
+}
+
+func TestMatchedPrograms(t *testing.T) {
+	at := func(d, h, m int) time.Time { return time.Date(2015, 3, d, h, m, 0, 0, time.UTC) }
+	list := []hiradio.Program{
+		{StartTime: "07:00", EndTime: "09:00", Name: "HIT DJ 早安"},
+		{StartTime: "23:00", EndTime: "01:00", Name: "夜光家族"},
+	}
+	got := matchedPrograms(at(2, 0, 30), list, []string{"夜光"})
+	want := []archive.Program{
+		// on air since the day before
+		{Name: "夜光家族", Start: at(1, 23, 0), Stop: at(2, 1, 0)},
+		{Name: "夜光家族", Start: at(2, 23, 0), Stop: at(3, 1, 0)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestPodcastFeedList(t *testing.T) {
+	recordings := []recording.Recording{
+		{ChannelID: 222, ChannelTitle: "HitFm", Program: "HIT DJ"},
+		{ChannelID: 222, ChannelTitle: "HitFm", Program: "HITO唱片行"},
+		{ChannelID: 222, ChannelTitle: "HitFm", Program: "HIT DJ"},
+	}
+	want := []podcastFeedInfo{
+		{"HitFm", "/podcast/222.xml", 3},
+		{"HIT DJ - HitFm", "/podcast/222/HIT%20DJ.xml", 2},
+		{"HITO唱片行 - HitFm", "/podcast/222/HITO%E5%94%B1%E7%89%87%E8%A1%8C.xml", 1},
+	}
+	got := podcastFeedList(recordings)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got[i], want[i])
+		}
+	}
+}
+
+func TestImageURL(t *testing.T) {
+	for _, c := range []struct {
+		name, want string
+	}{
+		{"14abcde694d00000b2fc.jpg", "http://hichannel.hinet.net/upload/radio/channel/14abcde694d00000b2fc.jpg"},
+		{"http://example.com/logo.png", "http://example.com/logo.png"},
+		{"", ""},
+	} {
+		if got := imageURL(c.name); got != c.want {
+			t.Errorf("imageURL(%q) got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPodcastServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hiradio")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2015, 3, 1, 17, 0, 0, 0, time.UTC)
+	r := recording.Recording{
+		ChannelID:    222,
+		ChannelTitle: "HitFm",
+		ChannelImage: "14abcde694d00000b2fc.jpg",
+		Program:      "AC/DC 精選",
+		Start:        start,
+		Stop:         start.Add(1 * time.Hour),
+	}
+	r.File = recording.FileName(r.ChannelID, r.Program, r.Start, ".ts")
+	if err := os.MkdirAll(filepath.Dir(r.Path(dir)), 0755); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if err := ioutil.WriteFile(r.Path(dir), []byte("ts"), 0644); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if err := recording.Save(dir, r); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	feeds := podcastFeedList([]recording.Recording{r})
+	if got, want := feeds[1].URL, "/podcast/222/AC%2FDC%20%E7%B2%BE%E9%81%B8.xml"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	rw := httptest.NewRecorder()
+	(&podcastServer{dir}).ServeHTTP(rw, httptest.NewRequest("GET", feeds[1].URL, nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", rw.Code, http.StatusOK)
+	}
+	for _, want := range []string{
+		`<title>AC/DC 精選 - HitFm</title>`,
+		`<itunes:image href="http://hichannel.hinet.net/upload/radio/channel/14abcde694d00000b2fc.jpg"></itunes:image>`,
+	} {
+		if !strings.Contains(rw.Body.String(), want) {
+			t.Errorf("expected %s in feed:\n%s", want, rw.Body)
+		}
+	}
+}
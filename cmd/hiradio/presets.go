@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// builtinPlayers are the default player presets. Presets in the
+// configuration override them.
+//
+// Placeholders:
+//
+//	{url}	URL of the playlist
+//	{title}	title of the channel
+//	{id}	ChannelID
+var builtinPlayers = map[string]string{
+	"mpv":     "mpv --no-video {url}",
+	"vlc":     "vlc --intf dummy {url}",
+	"ffplay":  "ffplay -nodisp {url}",
+	"mplayer": "mplayer {url}",
+}
+
+// playerDetectOrder is the order of presets to detect the installed player.
+var playerDetectOrder = []string{"mpv", "vlc", "ffplay", "mplayer"}
+
+// playerPresets merges user defined presets into builtin presets.
+func playerPresets(custom map[string]string) map[string]string {
+	presets := make(map[string]string, len(builtinPlayers)+len(custom))
+	for name, tmpl := range builtinPlayers {
+		presets[name] = tmpl
+	}
+	for name, tmpl := range custom {
+		presets[name] = tmpl
+	}
+	return presets
+}
+
+// presetNames returns sorted names of presets.
+func presetNames(presets map[string]string) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolvePlayer returns the command template of the player. The value may be
+// a preset name, a command template with placeholders, or a path of the
+// player binary which will be invoked as "app {url}".
+func resolvePlayer(value string, presets map[string]string) ([]string, error) {
+	if tmpl, found := presets[value]; found {
+		return splitArgs(tmpl)
+	}
+	if strings.Contains(value, "{") {
+		return splitArgs(value)
+	}
+	return []string{value, "{url}"}, nil
+}
+
+// detectPlayer returns the first preset whose binary is found in $PATH.
+func detectPlayer(presets map[string]string, lookPath func(string) (string, error)) (string, bool) {
+	names := append([]string{}, playerDetectOrder...)
+	for _, name := range presetNames(presets) {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		tmpl, found := presets[name]
+		if !found {
+			continue
+		}
+		args, err := splitArgs(tmpl)
+		if err != nil || len(args) == 0 {
+			continue
+		}
+		if _, err := lookPath(args[0]); err == nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// expandArgs replaces placeholders in args. The url is appended if there is
+// no {url} placeholder.
+func expandArgs(args []string, url, title string, channelID int) []string {
+	r := strings.NewReplacer(
+		"{url}", url,
+		"{title}", title,
+		"{id}", strconv.Itoa(channelID),
+	)
+	expanded := make([]string, 0, len(args)+1)
+	hasURL := false
+	for _, arg := range args {
+		if strings.Contains(arg, "{url}") {
+			hasURL = true
+		}
+		expanded = append(expanded, r.Replace(arg))
+	}
+	if !hasURL {
+		expanded = append(expanded, url)
+	}
+	return expanded
+}
+
+var errUnterminatedQuote = errors.New("unterminated quote")
+
+// splitArgs splits s into arguments separated by spaces. Single or double
+// quotes group the words.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var arg []rune
+	var quote rune
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg = append(arg, r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, string(arg))
+				arg = arg[:0]
+				inArg = false
+			}
+		default:
+			arg = append(arg, r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errUnterminatedQuote
+	}
+	if inArg {
+		args = append(args, string(arg))
+	}
+	return args, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// playerUsage returns the description of presets for the usage message.
+func playerUsage(presets map[string]string) string {
+	var buf []string
+	for _, name := range presetNames(presets) {
+		installed := ""
+		args, err := splitArgs(presets[name])
+		if err == nil && len(args) > 0 {
+			if _, err := exec.LookPath(args[0]); err == nil {
+				installed = " (installed)"
+			}
+		}
+		buf = append(buf, fmt.Sprintf("    %-10s %s%s", name, presets[name], installed))
+	}
+	return strings.Join(buf, "\n")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"mpv --no-video {url}", []string{"mpv", "--no-video", "{url}"}},
+		{`  vlc   --meta-title "{title} radio" {url} `, []string{"vlc", "--meta-title", "{title} radio", "{url}"}},
+		{`'/opt/my player/bin' ''`, []string{"/opt/my player/bin", ""}},
+		{"", nil},
+	}
+	for _, test := range tests {
+		got, err := splitArgs(test.in)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("splitArgs(%q): got %q, want %q", test.in, got, test.want)
+		}
+	}
+
+	if _, err := splitArgs(`mpv "{url}`); err != errUnterminatedQuote {
+		t.Fatalf("expected errUnterminatedQuote, got %v", err)
+	}
+}
+
+func TestResolvePlayer(t *testing.T) {
+	presets := playerPresets(map[string]string{"vlc": "cvlc {url}"})
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"mpv", []string{"mpv", "--no-video", "{url}"}},
+		{"vlc", []string{"cvlc", "{url}"}},
+		{"mplayer -really-quiet {url}", []string{"mplayer", "-really-quiet", "{url}"}},
+		{"/Applications/VLC app/vlc", []string{"/Applications/VLC app/vlc", "{url}"}},
+	}
+	for _, test := range tests {
+		got, err := resolvePlayer(test.in, presets)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("resolvePlayer(%q): got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestExpandArgs(t *testing.T) {
+	got := expandArgs([]string{"vlc", "--meta-title", "{id} {title}", "{url}"}, "http://localhost:1077/stream/222.m3u8", "HitFm", 222)
+	want := []string{"vlc", "--meta-title", "222 HitFm", "http://localhost:1077/stream/222.m3u8"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	got = expandArgs([]string{"mplayer"}, "http://localhost:1077/stream/222.m3u8", "", 222)
+	want = []string{"mplayer", "http://localhost:1077/stream/222.m3u8"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestDetectPlayer(t *testing.T) {
+	installed := map[string]bool{"ffplay": true, "vlc": true}
+	lookPath := func(file string) (string, error) {
+		if installed[file] {
+			return "/usr/bin/" + file, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	got, found := detectPlayer(playerPresets(nil), lookPath)
+	if !found || got != "vlc" {
+		t.Fatalf("got %q, want %q", got, "vlc")
+	}
+
+	installed = map[string]bool{}
+	if _, found := detectPlayer(playerPresets(nil), lookPath); found {
+		t.Fatal("expected no player to be detected")
+	}
+}
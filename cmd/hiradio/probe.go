@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/parkghost/hiradio"
+	"github.com/parkghost/hiradio/cmd/internal/probe"
+)
+
+// stagePlay is the stage of resolving the playlist URL by play.do.
+const stagePlay = "play"
+
+func probeCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("probe", flag.ExitOnError)
+	segments := fs.Int("segments", 2, "Number of segments fetched from each stream")
+	concurrency := fs.Int("concurrency", defaultConcurrency, "Maximum number of channels probed concurrently")
+	brokenOnly := fs.Bool("broken", false, "Display broken channels only")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio probe [options] [ChannelID...]
+
+Check the streams of channels (all channels if none is given): reachability,
+HTTP status, codec, declared vs measured bitrate, time to first byte and errors
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if *segments < 1 {
+		Fatalf("Invalid number of segments: %d", *segments)
+	}
+	if *concurrency < 1 {
+		Fatalf("Invalid concurrency: %d", *concurrency)
+	}
+
+	channels, err := client.ListChannels()
+	if err != nil {
+		Fatal(err)
+	}
+	if fs.NArg() > 0 {
+		var ids []int
+		for _, arg := range fs.Args() {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				Fatalf("Failed to parse ChannelID: %s", arg)
+			}
+			ids = append(ids, id)
+		}
+		channels = selectChannels(channels, ids)
+	}
+
+	results := probeChannels(channels, *concurrency, func(channelID int) *probe.Result {
+		pl, err := client.GetPlaylist(channelID)
+		if err != nil {
+			return &probe.Result{Stage: stagePlay, Error: err.Error()}
+		}
+		return probe.Probe(httpClient, pl.URL, *segments)
+	})
+	if *brokenOnly {
+		broken := []channelProbe{}
+		for _, r := range results {
+			if !r.OK() {
+				broken = append(broken, r)
+			}
+		}
+		results = broken
+	}
+
+	if format == "json" {
+		printJSON(results)
+		return
+	}
+	printProbes(results)
+}
+
+// selectChannels returns the channels of ids in order, unknown ids are kept
+// with the ID only.
+func selectChannels(channels []hiradio.Channel, ids []int) []hiradio.Channel {
+	var selected []hiradio.Channel
+	for _, id := range ids {
+		c := hiradio.Channel{ID: id}
+		for _, e := range channels {
+			if e.ID == id {
+				c = e
+				break
+			}
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+// channelProbe is the probe result of a channel.
+type channelProbe struct {
+	ChannelID int    `json:"channel_id"`
+	Title     string `json:"title"`
+	*probe.Result
+}
+
+// probeChannels probes channels with at most concurrency channels in flight,
+// the result keeps the order of channels.
+func probeChannels(channels []hiradio.Channel, concurrency int, probeChannel func(channelID int) *probe.Result) []channelProbe {
+	results := make([]channelProbe, len(channels))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, c := range channels {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, c hiradio.Channel) {
+			defer wg.Done()
+			results[i] = channelProbe{c.ID, c.Title, probeChannel(c.ID)}
+			<-sem
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
+
+func printProbes(results []channelProbe) {
+	fmt.Printf("%4s  %-24s  %-4s  %4s  %-9s  %5s  %5s  %6s\n",
+		"ID", "Channel", "", "HTTP", "Codecs", "Decl", "Meas", "TTFB")
+	broken := 0
+	for _, r := range results {
+		title := r.Title
+		wTitle := 24 - stringWidth(title) + len([]rune(title))
+		state := "OK"
+		if !r.OK() {
+			state = "FAIL"
+			broken++
+		}
+		status := "-"
+		if r.Status != 0 {
+			status = strconv.Itoa(r.Status)
+		}
+		fmt.Printf("%4d  %-*s  %-4s  %4s  %-9s  %5s  %5s  %6s",
+			r.ChannelID, wTitle, title, state, status, r.Codecs,
+			formatBitrate(r.DeclaredBitrate), formatBitrate(r.MeasuredBitrate),
+			formatTTFB(r.TTFB))
+		if !r.OK() {
+			fmt.Printf("  %s: %s", r.Stage, r.Error)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%d of %d channels broken\n", broken, len(results))
+}
+
+// formatBitrate returns the bitrate in kbps.
+func formatBitrate(bps int) string {
+	if bps == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%dk", (bps+500)/1000)
+}
+
+// formatTTFB returns the duration in milliseconds.
+func formatTTFB(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%dms", d/time.Millisecond)
+}
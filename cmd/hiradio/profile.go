@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/surma-dump/goappdata"
+)
+
+const (
+	defaultProfile = "default"
+	profileEnv     = envPrefix + "_PROFILE"
+	profilesDir    = "profiles"
+)
+
+// profile is the name of current configuration profile.
+var profile = defaultProfile
+
+var profileNameRE = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func validateProfile(name string) error {
+	if !profileNameRE.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q, only letters, digits, '-' and '_' are allowed", name)
+	}
+	return nil
+}
+
+// profileFromArgs returns the profile from the global -profile flag in args,
+// the HIRADIO_PROFILE environment variable or the default profile. It scans
+// the arguments before the command with the global flags in fs, because the
+// profile must be loaded before the flags are parsed.
+func profileFromArgs(fs *flag.FlagSet, args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == "profile" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, "profile=") {
+			return strings.TrimPrefix(name, "profile=")
+		}
+		// skip the value of the flag, unknown flags are assumed to take one
+		if !strings.Contains(name, "=") && !isBoolFlag(fs, name) && i+1 < len(args) {
+			i++
+		}
+	}
+	if name := os.Getenv(profileEnv); name != "" {
+		return name
+	}
+	return defaultProfile
+}
+
+// isBoolFlag reports whether the flag of fs takes no value.
+func isBoolFlag(fs *flag.FlagSet, name string) bool {
+	f := fs.Lookup(name)
+	if f == nil {
+		return false
+	}
+	b, ok := f.Value.(interface {
+		IsBoolFlag() bool
+	})
+	return ok && b.IsBoolFlag()
+}
+
+// profilePath returns the path of configuration file of the profile. The
+// default profile is stored in config.json, the others are stored in
+// profiles/NAME.json.
+func profilePath(name string) (string, error) {
+	if name == defaultProfile {
+		return configPath(configFile)
+	}
+	dir, err := goappdata.CreatePath("hiradio")
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, profilesDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// listProfiles returns the sorted names of existing profiles, the default
+// profile is always included.
+func listProfiles() ([]string, error) {
+	path, err := profilePath(defaultProfile)
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(path), profilesDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{defaultProfile}
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".json")
+		if validateProfile(name) == nil && name != defaultProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names, nil
+}
+
+func profileExists(name string) (bool, error) {
+	path, err := profilePath(name)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// checkProfile returns an error if the profile does not exist, so a mistyped
+// name is not created silently. The default profile always exists.
+func checkProfile(name string) error {
+	if name == defaultProfile {
+		return nil
+	}
+	exists, err := profileExists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("profile %q not found, create it by \"hiradio config profiles create %s\"", name, name)
+	}
+	return nil
+}
+
+// createProfile creates an empty profile.
+func createProfile(name string) error {
+	return writeProfile(name, []byte("{}\n"))
+}
+
+// copyProfile creates the profile dst with settings of src.
+func copyProfile(src, dst string) error {
+	if err := validateProfile(src); err != nil {
+		return err
+	}
+	path, err := profilePath(src)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("profile %q not found", src)
+		}
+		return err
+	}
+	return writeProfile(dst, data)
+}
+
+func writeProfile(name string, data []byte) error {
+	if err := validateProfile(name); err != nil {
+		return err
+	}
+	exists, err := profileExists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("profile %q already exists", name)
+	}
+	path, err := profilePath(name)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
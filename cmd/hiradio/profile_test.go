@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestProfileFromArgs(t *testing.T) {
+	os.Unsetenv(profileEnv)
+	fs := globalFlagSet()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"play", "222"}, defaultProfile},
+		{[]string{"-profile", "alice", "play"}, "alice"},
+		{[]string{"--profile=bob", "play"}, "bob"},
+		{[]string{"-timeout", "10s", "-profile", "alice", "play"}, "alice"},
+		{[]string{"-timeout", "profile", "play"}, defaultProfile},
+		{[]string{"-debug", "-profile", "work", "play"}, "work"},
+		{[]string{"-debug=true", "-profile", "work", "play"}, "work"},
+		// flags of the command are not global flags
+		{[]string{"play", "-profile", "alice"}, defaultProfile},
+	}
+	for _, test := range tests {
+		if got := profileFromArgs(fs, test.args); got != test.want {
+			t.Fatalf("profileFromArgs(%q): got %s, want %s", test.args, got, test.want)
+		}
+	}
+
+	os.Setenv(profileEnv, "carol")
+	defer os.Unsetenv(profileEnv)
+	if got := profileFromArgs(fs, []string{"play"}); got != "carol" {
+		t.Fatalf("got %s, want %s", got, "carol")
+	}
+	if got := profileFromArgs(fs, []string{"-profile", "alice", "play"}); got != "alice" {
+		t.Fatalf("got %s, want %s", got, "alice")
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	for _, name := range []string{"default", "alice", "team-1", "vlc_8080"} {
+		if err := validateProfile(name); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+	for _, name := range []string{"", "../etc", "a b", "a/b"} {
+		if err := validateProfile(name); err == nil {
+			t.Fatalf("expected error on profile name %q", name)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/rankings"
+)
+
+const rankingsFile = "rankings.jsonl"
+
+func rankingsCmd(args []string) {
+	if len(args) > 0 && args[0] == "collect" {
+		collectRankings(args[1:])
+		return
+	}
+
+	// flag settings
+	fs := flag.NewFlagSet("rankings", flag.ExitOnError)
+	from := fs.String("from", "", "Start date of the report (YYYY-MM-DD)")
+	to := fs.String("to", "", "End date of the report, inclusive (YYYY-MM-DD)")
+	top := fs.Int("top", 10, "Number of movers and channels to display")
+	channelID := fs.Int("channel", 0, "Display the rank chart of the channel only")
+	asCSV := fs.Bool("csv", false, "Export snapshots in CSV format")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio rankings [options]
+       hiradio rankings collect
+
+Report ranking trends from collected snapshots, or collect a snapshot of
+current rankings (suitable for cron)
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return
+	}
+
+	// load snapshots in date range
+	var fromTime, toTime time.Time
+	var err error
+	if *from != "" {
+		if fromTime, err = time.ParseInLocation("2006-01-02", *from, time.Local); err != nil {
+			Fatalf("Failed to parse date: %s", err)
+		}
+	}
+	if *to != "" {
+		if toTime, err = time.ParseInLocation("2006-01-02", *to, time.Local); err != nil {
+			Fatalf("Failed to parse date: %s", err)
+		}
+		toTime = toTime.AddDate(0, 0, 1)
+	}
+	path, err := configPath(rankingsFile)
+	if err != nil {
+		Fatalf("Failed to load rankings: %s", err)
+	}
+	snapshots, err := rankings.Load(path, fromTime, toTime)
+	if err != nil {
+		Fatalf("Failed to load rankings: %s", err)
+	}
+	if len(snapshots) == 0 {
+		Fatal(`No ranking snapshots, collect them by "hiradio rankings collect"`)
+	}
+
+	if *asCSV {
+		exportRankings(snapshots)
+		return
+	}
+	printRankingReport(snapshots, *top, *channelID)
+}
+
+func collectRankings(args []string) {
+	fs := flag.NewFlagSet("rankings collect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio rankings collect
+
+Collect a snapshot of current rankings`)
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return
+	}
+
+	list, err := client.ListRankings()
+	if err != nil {
+		Fatal(err)
+	}
+	path, err := configPath(rankingsFile)
+	if err != nil {
+		Fatalf("Failed to save rankings: %s", err)
+	}
+	s := rankings.Snapshot{Time: time.Now(), Rankings: list}
+	if err := rankings.Append(path, s); err != nil {
+		Fatalf("Failed to save rankings: %s", err)
+	}
+}
+
+// exportRankings writes snapshots in CSV format: time,channel_id,rank.
+func exportRankings(snapshots []rankings.Snapshot) {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"time", "channel_id", "rank"})
+	for _, s := range snapshots {
+		t := s.Time.Format(time.RFC3339)
+		for _, r := range s.Rankings {
+			w.Write([]string{t, strconv.Itoa(r.ID), strconv.Itoa(r.Value)})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		Fatal(err)
+	}
+}
+
+func printRankingReport(snapshots []rankings.Snapshot, top, channelID int) {
+	titles := channelTitles()
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	bottom := 0
+	for _, s := range snapshots {
+		for _, r := range s.Rankings {
+			if r.Value > bottom {
+				bottom = r.Value
+			}
+		}
+	}
+
+	fmt.Printf("期間: %s ~ %s (%d snapshots)\n",
+		first.Time.Local().Format("2006-01-02 15:04"),
+		last.Time.Local().Format("2006-01-02 15:04"),
+		len(snapshots))
+
+	// rank chart of one channel
+	if channelID > 0 {
+		series := rankings.Series(snapshots, channelID)
+		fmt.Printf("%4d  %s  %s\n", channelID, titles[channelID], rankings.Sparkline(series, bottom))
+		return
+	}
+
+	// movers
+	fmt.Println("排行變動:")
+	moves := rankings.Movers(first, last)
+	if len(moves) > top {
+		moves = moves[:top]
+	}
+	for _, m := range moves {
+		arrow := "↑"
+		delta := m.Delta(bottom)
+		if delta < 0 {
+			arrow = "↓"
+			delta = -delta
+		}
+		fmt.Printf("  %s%-3d %4d  %s  %s → %s\n",
+			arrow, delta, m.ChannelID, titles[m.ChannelID], formatRank(m.From), formatRank(m.To))
+	}
+
+	// rank charts of top channels
+	fmt.Println("排行走勢:")
+	for _, r := range last.Top(top) {
+		series := rankings.Series(snapshots, r.ID)
+		title := titles[r.ID]
+		wTitle := 30 - stringWidth(title) + len([]rune(title))
+		fmt.Printf("  %4d  %4d  %-*s  %s\n", r.Value, r.ID, wTitle, title, rankings.Sparkline(series, bottom))
+	}
+}
+
+func formatRank(rank int) string {
+	if rank == 0 {
+		return "-"
+	}
+	return strconv.Itoa(rank)
+}
+
+// channelTitles returns titles of channels, it is empty if failed to fetch
+// channels.
+func channelTitles() map[int]string {
+	titles := make(map[int]string)
+	channels, err := client.ListChannels()
+	if err != nil {
+		Warnf("Failed to fetch channel titles: %s", err)
+		return titles
+	}
+	for _, c := range channels {
+		titles[c.ID] = c.Title
+	}
+	return titles
+}
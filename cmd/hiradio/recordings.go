@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/recording"
+)
+
+func recordingsCmd(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio recordings ls [options]
+       hiradio recordings rm FILE...
+       hiradio recordings prune [options]
+
+List, remove or prune recorded programs by retention rules
+
+Run "hiradio recordings ls -h" or "hiradio recordings prune -h" for the options`)
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		usage()
+		return
+	}
+
+	dir := recordingsDir()
+	switch args[0] {
+	case "ls":
+		recordingsList(dir, args[1:])
+	case "rm":
+		if len(args) < 2 {
+			usage()
+			return
+		}
+		recordingsRemove(dir, args[1:])
+	case "prune":
+		recordingsPrune(dir, args[1:])
+	default:
+		usage()
+	}
+}
+
+func recordingsList(dir string, args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("recordings ls", flag.ExitOnError)
+	channelID := fs.Int("channel", 0, "List recordings of the channel only")
+	program := fs.String("program", "", "List recordings whose program name contains the text only")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio recordings ls [options]
+
+List recorded programs, the most recent first
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return
+	}
+
+	list := loadRecordings(dir)
+	filtered := []recording.Recording{}
+	for _, r := range list {
+		if *channelID != 0 && r.ChannelID != *channelID {
+			continue
+		}
+		if *program != "" && !matchProgram(r.Program, []string{*program}) {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+
+	if format == "json" {
+		printJSON(filtered)
+		return
+	}
+	var total int64
+	for _, r := range filtered {
+		wProgram := 24 - stringWidth(r.Program) + len([]rune(r.Program))
+		fmt.Printf("%s  %8s  %6s  %4d  %-*s  %s\n",
+			r.Start.Format("2006-01-02 15:04"),
+			formatElapsed(r.Duration()),
+			formatSize(r.Size),
+			r.ChannelID,
+			wProgram, r.Program,
+			r.File)
+		total += r.Size
+	}
+	fmt.Printf("%d recordings, %s in %s\n", len(filtered), formatSize(total), dir)
+}
+
+func recordingsRemove(dir string, files []string) {
+	list := loadRecordings(dir)
+	for _, name := range files {
+		name = strings.TrimPrefix(name, "/")
+		found := false
+		for _, r := range list {
+			if r.File == name {
+				found = true
+				if err := recording.Remove(dir, r); err != nil {
+					Fatalf("Failed to remove %s: %s", name, err)
+				}
+				fmt.Printf("Removed %s\n", name)
+			}
+		}
+		if !found {
+			Warnf("Recording not found: %s", name)
+		}
+	}
+}
+
+func recordingsPrune(dir string, args []string) {
+	policy, err := recordingsPolicy()
+	if err != nil {
+		Fatal(err)
+	}
+
+	// flag settings
+	fs := flag.NewFlagSet("recordings prune", flag.ExitOnError)
+	fs.IntVar(&policy.KeepLast, "keep", policy.KeepLast, "Number of the most recent recordings kept for each program, 0 means unlimited")
+	fs.DurationVar(&policy.MaxAge, "max-age", policy.MaxAge, "Maximum age of recordings, 0 means unlimited")
+	maxSize := fs.String("max-size", formatSize(policy.MaxSize), "Maximum total size of recordings, e.g. 500M or 10G, 0 means unlimited")
+	dryRun := fs.Bool("n", false, "Print the expired recordings without removing them")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio recordings prune [options]
+
+Remove recordings expired by retention rules, the defaults are given by the
+recordingsKeep, recordingsMaxAge and recordingsMaxSize settings
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return
+	}
+	if policy.MaxSize, err = parseSize(*maxSize); err != nil {
+		Fatalf("Failed to parse max size: %s", err)
+	}
+	if policy.IsZero() {
+		Fatal("No retention rules, use -keep, -max-age or -max-size")
+	}
+
+	if err := pruneRecordings(dir, policy, *dryRun); err != nil {
+		Fatal(err)
+	}
+}
+
+// pruneRecordings removes the recordings in dir expired by the policy.
+func pruneRecordings(dir string, policy recording.Policy, dryRun bool) error {
+	list, err := recording.Load(dir)
+	if err != nil {
+		return err
+	}
+	var freed int64
+	expired := recording.Expired(list, policy, time.Now())
+	for _, r := range expired {
+		if dryRun {
+			fmt.Printf("Expired %s (%s)\n", r.File, formatSize(r.Size))
+		} else {
+			if err := recording.Remove(dir, r); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s (%s)\n", r.File, formatSize(r.Size))
+		}
+		freed += r.Size
+	}
+	if len(expired) > 0 && !dryRun {
+		fmt.Printf("%d recordings, %s freed\n", len(expired), formatSize(freed))
+	}
+	return nil
+}
+
+// recordingsPolicy returns the retention rules of the settings.
+func recordingsPolicy() (recording.Policy, error) {
+	maxSize, err := parseSize(cfg.GetString(recordingsMaxSizeKey, ""))
+	if err != nil {
+		return recording.Policy{}, fmt.Errorf("invalid %s: %s", recordingsMaxSizeKey, err)
+	}
+	return recording.Policy{
+		KeepLast: cfg.GetInt(recordingsKeepKey, 0),
+		MaxAge:   cfg.GetDuration(recordingsMaxAgeKey, 0),
+		MaxSize:  maxSize,
+	}, nil
+}
+
+func loadRecordings(dir string) []recording.Recording {
+	list, err := recording.Load(dir)
+	if err != nil {
+		Fatalf("Failed to load recordings: %s", err)
+	}
+	return list
+}
+
+var errInvalidSize = errors.New("invalid size")
+
+// sizeUnits are the binary multiples of size suffixes.
+var sizeUnits = []string{"K", "M", "G", "T"}
+
+// parseSize parses a size in bytes with an optional suffix K, M, G or T, e.g.
+// 1.5G. An empty string is zero.
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	if s == "" {
+		return 0, nil
+	}
+	multiple := int64(1)
+	for i, u := range sizeUnits {
+		if strings.HasSuffix(s, u) {
+			s = strings.TrimSuffix(s, u)
+			multiple = 1 << (10 * uint(i+1))
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, errInvalidSize
+	}
+	return int64(f * float64(multiple)), nil
+}
+
+// formatSize returns the size in bytes with a suffix, e.g. 1.5G.
+func formatSize(n int64) string {
+	if n < 1024 {
+		return strconv.FormatInt(n, 10)
+	}
+	f := float64(n)
+	unit := ""
+	for _, u := range sizeUnits {
+		if f < 1024 {
+			break
+		}
+		f /= 1024
+		unit = u
+	}
+	return strconv.FormatFloat(f, 'f', 1, 64) + unit
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{
+		"":      0,
+		"0":     0,
+		"512":   512,
+		"100K":  100 << 10,
+		"1.5G":  3 << 29,
+		"10gb":  10 << 30,
+		" 2T ":  2 << 40,
+		"500MB": 500 << 20,
+	} {
+		got, err := parseSize(s)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if got != want {
+			t.Errorf("parseSize(%q) got %d, want %d", s, got, want)
+		}
+	}
+	for _, s := range []string{"G", "-1M", "1X"} {
+		if _, err := parseSize(s); err != errInvalidSize {
+			t.Errorf("parseSize(%q) got %v, want %v", s, err, errInvalidSize)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:       "0",
+		1023:    "1023",
+		1536:    "1.5K",
+		3 << 29: "1.5G",
+	} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) got %s, want %s", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/parkghost/hiradio"
+)
+
+const defaultConcurrency = 8
+
+func searchCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	concurrency := fs.Int("concurrency", defaultConcurrency, "Maximum number of concurrent requests")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio search [options] QUERY
+
+Search programs of all channels by name (case-insensitive)
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return
+	}
+	query := strings.Join(fs.Args(), " ")
+	if *concurrency < 1 {
+		Fatalf("Invalid concurrency: %d", *concurrency)
+	}
+
+	channels, err := client.ListChannels()
+	if err != nil {
+		Fatal(err)
+	}
+	infos := fetchChannelInfos(channels, *concurrency, client.GetChannelInfo)
+	matches := searchPrograms(infos, query)
+
+	if format == "json" {
+		printJSON(matches)
+		return
+	}
+	if len(matches) == 0 {
+		fmt.Printf("No programs matching %q\n", query)
+		return
+	}
+	for _, m := range matches {
+		cursor := "  "
+		if m.Program.On {
+			cursor = ">>"
+		}
+		title := m.Title
+		wTitle := 30 - stringWidth(title) + len([]rune(title))
+		fmt.Printf("%s %4d  %-*s  %s ~ %s  %s\n",
+			cursor, m.ChannelID, wTitle, title, m.Program.StartTime, m.Program.EndTime, m.Program.Name)
+	}
+}
+
+// channelInfo is the program list of a channel.
+type channelInfo struct {
+	hiradio.Channel
+	Info *hiradio.ChannelInfo `json:"info"`
+}
+
+// fetchChannelInfos fetches program lists of channels with at most
+// concurrency requests in flight. The result keeps the order of channels,
+// channels failed to fetch are skipped with a warning.
+func fetchChannelInfos(channels []hiradio.Channel, concurrency int, fetch func(channelID int) (*hiradio.ChannelInfo, error)) []channelInfo {
+	infos := make([]*hiradio.ChannelInfo, len(channels))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, c := range channels {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i, channelID int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			info, err := fetch(channelID)
+			if err != nil {
+				Warnf("Failed to fetch program list of %d: %s", channelID, err)
+				return
+			}
+			infos[i] = info
+		}(i, c.ID)
+	}
+	wg.Wait()
+
+	var list []channelInfo
+	for i, info := range infos {
+		if info != nil {
+			list = append(list, channelInfo{channels[i], info})
+		}
+	}
+	return list
+}
+
+// programMatch is a program matched by search.
+type programMatch struct {
+	ChannelID int             `json:"channel_id"`
+	Title     string          `json:"channel_title"`
+	Program   hiradio.Program `json:"program"`
+}
+
+// searchPrograms returns programs whose name contains query, ignoring case.
+func searchPrograms(infos []channelInfo, query string) []programMatch {
+	query = strings.ToLower(query)
+	var matches []programMatch
+	for _, c := range infos {
+		for _, p := range c.Info.List {
+			if strings.Contains(strings.ToLower(p.Name), query) {
+				matches = append(matches, programMatch{c.ID, c.Info.Title, p})
+			}
+		}
+	}
+	return matches
+}
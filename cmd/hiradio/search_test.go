@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/parkghost/hiradio"
+)
+
+func TestFetchChannelInfos(t *testing.T) {
+	channels := []hiradio.Channel{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}}
+	var mu sync.Mutex
+	var running, peak int
+	fetch := func(channelID int) (*hiradio.ChannelInfo, error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		if channelID == 3 {
+			return nil, errors.New("channel not found")
+		}
+		return &hiradio.ChannelInfo{Title: string(rune('a' + channelID))}, nil
+	}
+
+	infos := fetchChannelInfos(channels, 2, fetch)
+	var ids []int
+	for _, c := range infos {
+		ids = append(ids, c.ID)
+	}
+	if want := []int{1, 2, 4, 5}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("got %v, want %v", ids, want)
+	}
+	if peak > 2 {
+		t.Fatalf("got %d concurrent requests, want at most 2", peak)
+	}
+}
+
+func TestSearchPrograms(t *testing.T) {
+	infos := []channelInfo{
+		{hiradio.Channel{ID: 222}, &hiradio.ChannelInfo{Title: "HitFm", List: []hiradio.Program{
+			{StartTime: "17:00", EndTime: "18:00", Name: "週日 HIT DJ", On: true},
+			{StartTime: "18:00", EndTime: "20:00", Name: "HITO唱片行"},
+		}}},
+		{hiradio.Channel{ID: 88}, &hiradio.ChannelInfo{Title: "HitFm 中部", List: []hiradio.Program{
+			{StartTime: "17:00", EndTime: "18:00", Name: "週日 Hit DJ", On: true},
+		}}},
+	}
+
+	got := searchPrograms(infos, "hit dj")
+	want := []programMatch{
+		{222, "HitFm", infos[0].Info.List[0]},
+		{88, "HitFm 中部", infos[1].Info.List[0]},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := searchPrograms(infos, "news"); len(got) != 0 {
+		t.Fatalf("got %v, want no matches", got)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parkghost/hiradio"
+	"github.com/parkghost/hiradio/cmd/internal/history"
+)
+
+// notifier sends desktop notifications.
+type notifier interface {
+	Notify(summary, body string) error
+}
+
+// dbusNotifier sends freedesktop notifications through D-Bus by gdbus.
+//
+// Spec: https://developer.gnome.org/notification-spec/
+type dbusNotifier struct {
+	appName string
+	timeout time.Duration
+}
+
+func (n *dbusNotifier) Notify(summary, body string) error {
+	cmd := exec.Command("gdbus", "call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		n.appName, "0", "", summary, body, "[]", "{}",
+		fmt.Sprint(int(n.timeout/time.Millisecond)))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// playStatus tracks the current and next program of the playing channel.
+type playStatus struct {
+	channelID int
+	fetch     func(channelID int) (*hiradio.ChannelInfo, error)
+	notifier  notifier
+	start     time.Time
+
+	mu       sync.Mutex
+	title    string
+	typeText string
+	current  *hiradio.Program
+	next     *hiradio.Program
+	heard    []history.Program
+}
+
+// Refresh fetches the program list and sends a notification if the current
+// program is changed.
+func (s *playStatus) Refresh() error {
+	info, err := s.fetch(s.channelID)
+	if err != nil {
+		return err
+	}
+
+	var current, next *hiradio.Program
+	for i, p := range info.List {
+		if p.On {
+			current = &info.List[i]
+			if i+1 < len(info.List) {
+				next = &info.List[i+1]
+			}
+			break
+		}
+	}
+
+	s.mu.Lock()
+	changed := s.title != "" && current != nil &&
+		(s.current == nil || *s.current != *current)
+	s.title = info.Title
+	s.typeText = info.TypeText
+	s.current = current
+	s.next = next
+	if current != nil && (len(s.heard) == 0 || s.heard[len(s.heard)-1].Name != current.Name) {
+		s.heard = append(s.heard, history.Program{Name: current.Name, Start: time.Now()})
+	}
+	s.mu.Unlock()
+
+	if changed && s.notifier != nil {
+		body := fmt.Sprintf("%s ~ %s  %s", current.StartTime, current.EndTime, current.Name)
+		if err := s.notifier.Notify(info.Title, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Session returns the listening session stopped at stop.
+func (s *playStatus) Session(stop time.Time) history.Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return history.Session{
+		ChannelID: s.channelID,
+		Title:     s.title,
+		Type:      s.typeText,
+		Start:     s.start,
+		Stop:      stop,
+		Programs:  append([]history.Program(nil), s.heard...),
+	}
+}
+
+// String returns the status line.
+func (s *playStatus) String() string {
+	return s.format(time.Now())
+}
+
+func (s *playStatus) format(now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := []string{formatElapsed(now.Sub(s.start))}
+	if s.title != "" {
+		parts = append(parts, s.title)
+	}
+	if s.current != nil {
+		parts = append(parts, fmt.Sprintf("現在: %s ~ %s %s", s.current.StartTime, s.current.EndTime, s.current.Name))
+	}
+	if s.next != nil {
+		parts = append(parts, fmt.Sprintf("下一個: %s %s", s.next.StartTime, s.next.Name))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// Run displays the status line on w until stop is closed. The status line is
+// redrawn every second and the program list is refreshed every interval.
+func (s *playStatus) Run(w io.Writer, interval time.Duration, stop <-chan struct{}) {
+	if err := s.Refresh(); err != nil {
+		Warnf("Failed to refresh program list: %s", err)
+	}
+
+	tick := time.NewTicker(1 * time.Second)
+	defer tick.Stop()
+	refresh := time.NewTicker(interval)
+	defer refresh.Stop()
+
+	var width int
+	for {
+		line := s.String() + "  Press ctrl-c to exit"
+		n := stringWidth(line)
+		pad := ""
+		if n < width {
+			pad = strings.Repeat(" ", width-n)
+		}
+		fmt.Fprintf(w, "\r%s%s", line, pad)
+		width = n
+
+		select {
+		case <-tick.C:
+		case <-refresh.C:
+			if err := s.Refresh(); err != nil {
+				Warnf("Failed to refresh program list: %s", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func formatElapsed(d time.Duration) string {
+	d = d / time.Second
+	return fmt.Sprintf("%02d:%02d:%02d", d/3600, d/60%60, d%60)
+}
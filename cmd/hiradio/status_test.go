@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parkghost/hiradio"
+)
+
+type fakeNotifier struct {
+	notifications [][2]string
+}
+
+func (n *fakeNotifier) Notify(summary, body string) error {
+	n.notifications = append(n.notifications, [2]string{summary, body})
+	return nil
+}
+
+func TestPlayStatusRefresh(t *testing.T) {
+	infos := []*hiradio.ChannelInfo{
+		{Title: "HitFm", List: []hiradio.Program{
+			{StartTime: "17:00", EndTime: "18:00", Name: "週日 HIT DJ", On: true},
+			{StartTime: "18:00", EndTime: "20:00", Name: "HITO唱片行", On: false},
+		}},
+		{Title: "HitFm", List: []hiradio.Program{
+			{StartTime: "17:00", EndTime: "18:00", Name: "週日 HIT DJ", On: true},
+			{StartTime: "18:00", EndTime: "20:00", Name: "HITO唱片行", On: false},
+		}},
+		{Title: "HitFm", List: []hiradio.Program{
+			{StartTime: "17:00", EndTime: "18:00", Name: "週日 HIT DJ", On: false},
+			{StartTime: "18:00", EndTime: "20:00", Name: "HITO唱片行", On: true},
+		}},
+	}
+	n := new(fakeNotifier)
+	start := time.Date(2015, 3, 1, 17, 30, 0, 0, time.UTC)
+	s := &playStatus{
+		channelID: 222,
+		fetch: func(channelID int) (*hiradio.ChannelInfo, error) {
+			info := infos[0]
+			infos = infos[1:]
+			return info, nil
+		},
+		notifier: n,
+		start:    start,
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := s.Refresh(); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	want := [][2]string{{"HitFm", "18:00 ~ 20:00  HITO唱片行"}}
+	if !reflect.DeepEqual(n.notifications, want) {
+		t.Fatalf("got %+v, want %+v", n.notifications, want)
+	}
+
+	got := s.format(start.Add(3723 * time.Second))
+	wantLine := "01:02:03  HitFm  現在: 18:00 ~ 20:00 HITO唱片行"
+	if got != wantLine {
+		t.Fatalf("got %q, want %q", got, wantLine)
+	}
+}
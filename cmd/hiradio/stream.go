@@ -0,0 +1,85 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+)
+
+// recordRetryDelay is the delay before recording again after an error.
+const recordRetryDelay = 5 * time.Second
+
+// newStream returns the live stream of the channel.
+func newStream(channelID int) *hls.Stream {
+	return &hls.Stream{
+		Client: httpClient,
+		URL: func() (string, error) {
+			pl, err := client.GetPlaylist(channelID)
+			if err != nil {
+				return "", err
+			}
+			upstreamRefreshes.Inc(strconv.Itoa(channelID))
+			return pl.URL, nil
+		},
+	}
+}
+
+// recordStream calls handle with segments of the stream until stop is closed
+// or the stream is ended. The stream is resumed after errors, an expired
+// playlist URL is fetched again immediately.
+func recordStream(channelID int, s *hls.Stream, stop <-chan struct{}, handle func(seg hls.Segment, data []byte) error) {
+	expiredAt := -1
+	for {
+		err := s.Run(stop, handle)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if err == nil {
+			Warnf("Stream of channel %d is ended", channelID)
+			return
+		}
+		if isExpired(err) && s.Sequence != expiredAt {
+			expiredAt = s.Sequence
+			Warnf("Playlist of channel %d is expired: %s, refetching", channelID, err)
+			s.Reset()
+			continue
+		}
+		Warnf("Failed to record channel %d: %s, retry in %s", channelID, err, recordRetryDelay)
+		s.Reset()
+		select {
+		case <-stop:
+			return
+		case <-time.After(recordRetryDelay):
+		}
+	}
+}
+
+// isExpired reports whether err is a response of an expired playlist URL.
+func isExpired(err error) bool {
+	if e, ok := err.(*hls.StatusError); ok {
+		return e.StatusCode == http.StatusForbidden || e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// segmentType returns the content type of the media segment.
+func segmentType(name string) string {
+	switch ext := path.Ext(name); ext {
+	case ".ts":
+		// .ts is registered as Qt translation in some systems
+		return "video/mp2t"
+	case ".aac":
+		return "audio/aac"
+	default:
+		if ct := mime.TypeByExtension(ext); ct != "" {
+			return ct
+		}
+	}
+	return "application/octet-stream"
+}
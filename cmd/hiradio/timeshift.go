@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+	"github.com/parkghost/hiradio/cmd/internal/timeshift"
+)
+
+// bufferWaitTimeout is the maximum time to wait for the first segment.
+const bufferWaitTimeout = 15 * time.Second
+
+func timeshiftCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("timeshift", flag.ExitOnError)
+	port := fs.Int("port", cfg.GetInt(proxyPortKey, defaultProxyPort), "Port of the proxy server")
+	channel := fs.Int("channel", 0, "The playing channel (the last played channel if zero)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio timeshift [options] pause|resume|live|status
+       hiradio timeshift [options] seek OFFSET
+
+Control the playback of "hiradio play -timeshift", e.g. "seek -5m" rewinds
+5 minutes and "live" returns to the live broadcast
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return
+	}
+
+	form := url.Values{"action": {fs.Arg(0)}}
+	switch fs.Arg(0) {
+	case "pause", "resume", "live", "status":
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return
+		}
+	case "seek":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return
+		}
+		if _, err := time.ParseDuration(fs.Arg(1)); err != nil {
+			Fatalf("Failed to parse offset: %s", err)
+		}
+		form.Set("offset", fs.Arg(1))
+	default:
+		fs.Usage()
+		return
+	}
+
+	channelID := *channel
+	if channelID == 0 {
+		id, err := getChannelID(nil)
+		if err != nil {
+			Fatal("No playing channel, specify it by -channel")
+		}
+		channelID = id
+	}
+
+	u := fmt.Sprintf("http://localhost:%d/timeshift/%d/control", *port, channelID)
+	var resp *http.Response
+	var err error
+	if fs.Arg(0) == "status" {
+		resp, err = http.Get(u)
+	} else {
+		resp, err = http.PostForm(u, form)
+	}
+	if err != nil {
+		Fatalf("Failed to connect to the player: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		Fatalf("Failed to control timeshift: %s", msg)
+	}
+	var st timeshift.Status
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		Fatalf("Failed to control timeshift: %s", err)
+	}
+
+	if format == "json" {
+		printJSON(st)
+		return
+	}
+	state := "Playing"
+	if st.Paused {
+		state = "Paused"
+	}
+	if st.Delay < time.Second {
+		fmt.Printf("%s live, %s buffered\n", state, formatElapsed(st.Buffered))
+	} else {
+		fmt.Printf("%s %s behind live, %s buffered\n", state, formatElapsed(st.Delay), formatElapsed(st.Buffered))
+	}
+}
+
+// timeshiftSession records a channel into a timeshift buffer.
+type timeshiftSession struct {
+	channelID int
+	buf       *timeshift.Buffer
+	ts        *timeshift.Timeshift
+	stream    *hls.Stream
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func newTimeshiftSession(channelID int, length time.Duration) (*timeshiftSession, error) {
+	dir, err := ioutil.TempDir("", fmt.Sprintf("hiradio-timeshift-%d-", channelID))
+	if err != nil {
+		return nil, err
+	}
+	buf, err := timeshift.NewBuffer(dir, length)
+	if err != nil {
+		return nil, err
+	}
+	s := &timeshiftSession{
+		channelID: channelID,
+		buf:       buf,
+		ts:        timeshift.New(buf, timeshift.DefaultWindow),
+		stream:    newStream(channelID),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.record()
+	return s, nil
+}
+
+// record downloads the stream into the buffer until the session is closed.
+func (s *timeshiftSession) record() {
+	defer close(s.done)
+	recordStream(s.channelID, s.stream, s.stop, func(seg hls.Segment, data []byte) error {
+		return s.buf.Add(seg, time.Now(), data)
+	})
+}
+
+// waitBuffer waits until the first segment arrives.
+func (s *timeshiftSession) waitBuffer(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for s.buf.Len() == 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		select {
+		case <-s.done:
+			return false
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return true
+}
+
+func (s *timeshiftSession) Close() error {
+	close(s.stop)
+	<-s.done
+	return s.buf.Close()
+}
+
+// timeshifts manages the timeshift sessions of the proxy.
+type timeshifts struct {
+	length time.Duration
+
+	mu       sync.Mutex
+	sessions map[int]*timeshiftSession
+}
+
+func (t *timeshifts) get(channelID int, create bool) (*timeshiftSession, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s, found := t.sessions[channelID]; found || !create {
+		return s, nil
+	}
+	s, err := newTimeshiftSession(channelID, t.length)
+	if err != nil {
+		return nil, err
+	}
+	if t.sessions == nil {
+		t.sessions = make(map[int]*timeshiftSession)
+	}
+	t.sessions[channelID] = s
+	return s, nil
+}
+
+// Close stops recording and removes the buffers.
+func (t *timeshifts) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, s := range t.sessions {
+		if err := s.Close(); err != nil {
+			Warnf("Failed to remove timeshift buffer: %s", err)
+		}
+		delete(t.sessions, id)
+	}
+}
+
+var timeshiftRouteRE = regexp.MustCompile(`^/timeshift/(\d+)/(playlist\.m3u8|control|(\d+)\.\w+)$`)
+
+func (t *timeshifts) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	matched := timeshiftRouteRE.FindStringSubmatch(req.URL.Path)
+	if matched == nil {
+		http.NotFound(rw, req)
+		return
+	}
+	channelID, _ := strconv.Atoi(matched[1])
+
+	switch {
+	case matched[2] == "playlist.m3u8":
+		s, err := t.get(channelID, true)
+		if err != nil {
+			Warnf("Failed to start timeshift: %s", err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !s.waitBuffer(bufferWaitTimeout) {
+			http.Error(rw, "stream is unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		rw.Header().Set("Cache-Control", "no-cache")
+		s.ts.Playlist(time.Now()).Encode(rw)
+
+	case matched[2] == "control":
+		s, _ := t.get(channelID, false)
+		if s == nil {
+			http.Error(rw, fmt.Sprintf("channel %d is not playing with timeshift", channelID), http.StatusNotFound)
+			return
+		}
+		if req.Method == "POST" {
+			if err := controlTimeshift(s.ts, req.FormValue("action"), req.FormValue("offset")); err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(s.ts.Status(time.Now()))
+
+	default:
+		s, _ := t.get(channelID, false)
+		if s == nil {
+			http.NotFound(rw, req)
+			return
+		}
+		seq, _ := strconv.Atoi(matched[3])
+		f, err := s.buf.Open(seq)
+		if err != nil {
+			http.NotFound(rw, req)
+			return
+		}
+		defer f.Close()
+		rw.Header().Set("Content-Type", segmentType(req.URL.Path))
+		io.Copy(rw, f)
+	}
+}
+
+func controlTimeshift(ts *timeshift.Timeshift, action, offset string) error {
+	now := time.Now()
+	switch action {
+	case "pause":
+		ts.Pause(now)
+	case "resume":
+		ts.Resume(now)
+	case "live":
+		ts.Live(now)
+	case "seek":
+		d, err := time.ParseDuration(offset)
+		if err != nil {
+			return err
+		}
+		ts.Seek(now, d)
+	case "status":
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/parkghost/hiradio"
+)
+
+func watchCmd(args []string) {
+	// flag settings
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := fs.Duration("interval", 1*time.Minute, "Polling interval")
+	asJSON := fs.Bool("json", format == "json", "Print events in JSON format")
+	hook := fs.String("exec", "", "Command to execute on each event, details are passed by HIRADIO_EVENT_* environment variables")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: hiradio watch [options] [ChannelID...]
+
+Watch now-playing program changes of channels (all channels if no ChannelID given)
+
+The options are:`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	// parse arguments
+	fs.Parse(args)
+	var channelIDs []int
+	for _, arg := range fs.Args() {
+		channelID, err := strconv.Atoi(arg)
+		if err != nil {
+			Fatalf("Failed to parse ChannelID: %s", arg)
+		}
+		channelIDs = append(channelIDs, channelID)
+	}
+	if *interval <= 0 {
+		Fatalf("Invalid interval: %s", *interval)
+	}
+
+	w := watcher{
+		channelIDs: channelIDs,
+		fetch:      fetchNowPlaying,
+	}
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		events, err := w.Poll()
+		if err != nil {
+			Warnf("Failed to poll channels: %s", err)
+		}
+		for _, e := range events {
+			printWatchEvent(e, *asJSON)
+			if *hook != "" {
+				if err := runHook(*hook, e.Env()); err != nil {
+					Warnf("Failed to execute hook: %s", err)
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// nowPlaying represents the current program of a channel.
+type nowPlaying struct {
+	ChannelID int
+	Title     string
+	Program   hiradio.Program
+}
+
+// watchEvent represents a change of the current program.
+type watchEvent struct {
+	Time      time.Time `json:"time"`
+	ChannelID int       `json:"channel_id"`
+	Title     string    `json:"channel_title"`
+	Program   string    `json:"program_name"`
+	Previous  string    `json:"previous_program_name"`
+	StartTime string    `json:"start_time,omitempty"`
+	EndTime   string    `json:"end_time,omitempty"`
+}
+
+// Env returns the event as environment variables for the hook command. They
+// are prefixed with HIRADIO_EVENT_ so that they don't override the settings of
+// hiradio commands run by the hook.
+func (e watchEvent) Env() []string {
+	return []string{
+		"HIRADIO_EVENT_TIME=" + e.Time.Format(time.RFC3339),
+		"HIRADIO_EVENT_CHANNEL_ID=" + strconv.Itoa(e.ChannelID),
+		"HIRADIO_EVENT_CHANNEL_TITLE=" + e.Title,
+		"HIRADIO_EVENT_PROGRAM_NAME=" + e.Program,
+		"HIRADIO_EVENT_PREVIOUS_PROGRAM_NAME=" + e.Previous,
+		"HIRADIO_EVENT_START_TIME=" + e.StartTime,
+		"HIRADIO_EVENT_END_TIME=" + e.EndTime,
+	}
+}
+
+type watcher struct {
+	channelIDs []int
+	fetch      func(channelIDs []int) ([]nowPlaying, error)
+
+	last map[int]nowPlaying
+}
+
+// Poll fetches the current programs and returns the changes since last poll.
+// The first poll only records the current state.
+func (w *watcher) Poll() ([]watchEvent, error) {
+	current, err := w.fetch(w.channelIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []watchEvent
+	now := time.Now()
+	first := w.last == nil
+	if first {
+		w.last = make(map[int]nowPlaying)
+	}
+	for _, np := range current {
+		prev, found := w.last[np.ChannelID]
+		w.last[np.ChannelID] = np
+		if first || !found || prev.Program == np.Program {
+			continue
+		}
+		events = append(events, watchEvent{
+			Time:      now,
+			ChannelID: np.ChannelID,
+			Title:     np.Title,
+			Program:   np.Program.Name,
+			Previous:  prev.Program.Name,
+			StartTime: np.Program.StartTime,
+			EndTime:   np.Program.EndTime,
+		})
+	}
+	return events, nil
+}
+
+// fetchNowPlaying fetches the current programs of specified channels, or of
+// all channels if channelIDs is empty. Channels failed to fetch are omitted,
+// an error is returned if all of them failed.
+func fetchNowPlaying(channelIDs []int) ([]nowPlaying, error) {
+	if len(channelIDs) == 0 {
+		channels, err := client.ListChannels()
+		if err != nil {
+			return nil, err
+		}
+		list := make([]nowPlaying, 0, len(channels))
+		for _, c := range channels {
+			list = append(list, nowPlaying{
+				ChannelID: c.ID,
+				Title:     c.Title,
+				Program:   hiradio.Program{Name: c.ProgramName},
+			})
+		}
+		return list, nil
+	}
+
+	// a failing channel is skipped and retried on the next poll
+	list := make([]nowPlaying, 0, len(channelIDs))
+	var lastErr error
+	for _, id := range channelIDs {
+		info, err := client.GetChannelInfo(id)
+		if err != nil {
+			Warnf("Failed to get channel %d: %s", id, err)
+			lastErr = err
+			continue
+		}
+		np := nowPlaying{ChannelID: id, Title: info.Title}
+		if p, ok := currentProgram(info); ok {
+			np.Program = p
+		}
+		list = append(list, np)
+	}
+	if len(list) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return list, nil
+}
+
+// currentProgram returns the first program which is on.
+func currentProgram(info *hiradio.ChannelInfo) (hiradio.Program, bool) {
+	for _, p := range info.List {
+		if p.On {
+			return p, true
+		}
+	}
+	return hiradio.Program{}, false
+}
+
+func printWatchEvent(e watchEvent, asJSON bool) {
+	if asJSON {
+		data, _ := json.Marshal(e)
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("%s  %4d  %s  %s -> %s\n",
+		e.Time.Format("2006-01-02 15:04:05"),
+		e.ChannelID,
+		e.Title,
+		e.Previous,
+		e.Program)
+}
+
+// runHook executes command through the shell with extra environment variables.
+func runHook(command string, env []string) error {
+	cmd := shellCommand(command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// shellCommand returns the command executed through the shell.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("/bin/sh", "-c", command)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/parkghost/hiradio"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	hitfm := func(name string) nowPlaying {
+		return nowPlaying{222, "HitFm", hiradio.Program{StartTime: "17:00", EndTime: "18:00", Name: name}}
+	}
+	kiss := func(name string) nowPlaying {
+		return nowPlaying{156, "KISS", hiradio.Program{Name: name}}
+	}
+	errFetch := errors.New("fetch failed")
+
+	tests := []struct {
+		current []nowPlaying
+		err     error
+		want    []string
+	}{
+		// the first poll only records the state
+		{[]nowPlaying{hitfm("週日 HIT DJ"), kiss("音樂玩家")}, nil, nil},
+		{[]nowPlaying{hitfm("週日 HIT DJ"), kiss("音樂玩家")}, nil, nil},
+		{[]nowPlaying{hitfm("HITO唱片行"), kiss("音樂玩家")}, nil, []string{"222 週日 HIT DJ -> HITO唱片行"}},
+		// failed polls keep the last state
+		{nil, errFetch, nil},
+		// a channel missing from a poll is compared with its last state
+		{[]nowPlaying{kiss("夜光家族")}, nil, []string{"156 音樂玩家 -> 夜光家族"}},
+		{[]nowPlaying{hitfm("HITO唱片行"), kiss("夜光家族")}, nil, nil},
+		// a new channel is recorded without an event
+		{[]nowPlaying{hitfm("HITO唱片行"), kiss("夜光家族"), {232, "飛碟", hiradio.Program{Name: "飛碟早餐"}}}, nil, nil},
+	}
+
+	var i int
+	w := &watcher{fetch: func(channelIDs []int) ([]nowPlaying, error) {
+		return tests[i].current, tests[i].err
+	}}
+	for ; i < len(tests); i++ {
+		events, err := w.Poll()
+		if err != tests[i].err {
+			t.Fatalf("%d: got err %v, want %v", i, err, tests[i].err)
+		}
+		var got []string
+		for _, e := range events {
+			got = append(got, fmt.Sprintf("%d %s -> %s", e.ChannelID, e.Previous, e.Program))
+		}
+		if !reflect.DeepEqual(got, tests[i].want) {
+			t.Fatalf("%d: got %q, want %q", i, got, tests[i].want)
+		}
+	}
+}
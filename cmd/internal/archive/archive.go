@@ -0,0 +1,383 @@
+// Package archive stores recorded segments of channels and indexes them by
+// programs.
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/parkghost/hiradio"
+)
+
+const (
+	segmentsDir  = "segments"
+	segmentsFile = "segments.jsonl"
+	programsFile = "programs.jsonl"
+)
+
+// Segment represents a recorded media segment.
+type Segment struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Name     string        `json:"name"`
+}
+
+// End returns the end time of the segment.
+func (s Segment) End() time.Time {
+	return s.Start.Add(s.Duration)
+}
+
+// Program represents a program on air in [Start, Stop).
+type Program struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	Stop  time.Time `json:"stop"`
+}
+
+// ResolvePrograms returns the programs of the schedule on the day, a program
+// which passes midnight stops on the next day.
+func ResolvePrograms(day time.Time, list []hiradio.Program) []Program {
+	y, m, d := day.Date()
+	at := func(clock string) (time.Time, bool) {
+		if clock == "24:00" {
+			return time.Date(y, m, d+1, 0, 0, 0, 0, day.Location()), true
+		}
+		t, err := time.Parse("15:04", clock)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, day.Location()), true
+	}
+
+	var programs []Program
+	for _, p := range list {
+		start, ok1 := at(p.StartTime)
+		stop, ok2 := at(p.EndTime)
+		if !ok1 || !ok2 {
+			continue
+		}
+		if !stop.After(start) {
+			stop = stop.AddDate(0, 0, 1)
+		}
+		programs = append(programs, Program{p.Name, start, stop})
+	}
+	return programs
+}
+
+// Archive stores the recordings of channels in a directory.
+type Archive struct {
+	dir string
+
+	mu       sync.Mutex
+	channels map[int]*Channel
+}
+
+// Open opens the archive in dir.
+func Open(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Archive{dir: dir, channels: make(map[int]*Channel)}, nil
+}
+
+// ChannelIDs returns the sorted IDs of archived channels.
+func (a *Archive) ChannelIDs() ([]int, error) {
+	files, err := ioutil.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, f := range files {
+		if id, err := strconv.Atoi(f.Name()); err == nil && f.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// Has reports whether the channel is in the archive.
+func (a *Archive) Has(id int) bool {
+	fi, err := os.Stat(filepath.Join(a.dir, strconv.Itoa(id)))
+	return err == nil && fi.IsDir()
+}
+
+// Channel returns the archive of the channel, the index is loaded on first
+// use.
+func (a *Archive) Channel(id int) (*Channel, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, found := a.channels[id]; found {
+		return c, nil
+	}
+	c := &Channel{dir: filepath.Join(a.dir, strconv.Itoa(id))}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	a.channels[id] = c
+	return c, nil
+}
+
+// Channel is the archive of a channel.
+type Channel struct {
+	dir string
+
+	mu       sync.Mutex
+	segments []Segment
+	programs []Program
+}
+
+func (c *Channel) load() error {
+	if err := os.MkdirAll(filepath.Join(c.dir, segmentsDir), 0755); err != nil {
+		return err
+	}
+	if err := loadJSONL(filepath.Join(c.dir, segmentsFile), func(line []byte) error {
+		var s Segment
+		if err := json.Unmarshal(line, &s); err != nil {
+			return err
+		}
+		c.segments = append(c.segments, s)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := loadJSONL(filepath.Join(c.dir, programsFile), func(line []byte) error {
+		var p Program
+		if err := json.Unmarshal(line, &p); err != nil {
+			return err
+		}
+		c.programs = append(c.programs, p)
+		return nil
+	}); err != nil {
+		return err
+	}
+	sort.Sort(segmentsByStart(c.segments))
+	sort.Sort(programsByStart(c.programs))
+	return nil
+}
+
+// Add stores the segment started at start. The extension ext of the file is
+// preserved, e.g. ".ts".
+func (c *Channel) Add(start time.Time, d time.Duration, ext string, data []byte) error {
+	s := Segment{Start: start, Duration: d, Name: fmt.Sprintf("%d%s", start.UnixNano(), ext)}
+	if err := ioutil.WriteFile(c.Path(s), data, 0644); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := appendJSONL(filepath.Join(c.dir, segmentsFile), s); err != nil {
+		return err
+	}
+	c.segments = append(c.segments, s)
+	return nil
+}
+
+// Path returns the path of the segment file.
+func (c *Channel) Path(s Segment) string {
+	return filepath.Join(c.dir, segmentsDir, s.Name)
+}
+
+// Segments returns the segments overlapping [from, to).
+func (c *Channel) Segments(from, to time.Time) []Segment {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var list []Segment
+	for _, s := range c.segments {
+		if s.End().After(from) && s.Start.Before(to) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// AddPrograms indexes the programs, a program with the same start time
+// replaces the indexed one.
+func (c *Channel) AddPrograms(programs []Program) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	changed := false
+	for _, p := range programs {
+		i := sort.Search(len(c.programs), func(i int) bool {
+			return !c.programs[i].Start.Before(p.Start)
+		})
+		if i < len(c.programs) && c.programs[i].Start.Equal(p.Start) {
+			if old := c.programs[i]; old.Name == p.Name && old.Stop.Equal(p.Stop) {
+				continue
+			}
+			c.programs[i] = p
+		} else {
+			c.programs = append(c.programs, Program{})
+			copy(c.programs[i+1:], c.programs[i:])
+			c.programs[i] = p
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return c.savePrograms()
+}
+
+// Programs returns the programs started in [from, to).
+func (c *Channel) Programs(from, to time.Time) []Program {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var list []Program
+	for _, p := range c.programs {
+		if !p.Start.Before(from) && p.Start.Before(to) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// Program returns the program started at start.
+func (c *Channel) Program(start time.Time) (Program, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range c.programs {
+		if p.Start.Equal(start) {
+			return p, true
+		}
+	}
+	return Program{}, false
+}
+
+// Prune removes the segments ended and the programs stopped before t.
+func (c *Channel) Prune(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var segments []Segment
+	for _, s := range c.segments {
+		if s.End().Before(t) {
+			if err := os.Remove(c.Path(s)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		segments = append(segments, s)
+	}
+	var programs []Program
+	for _, p := range c.programs {
+		if !p.Stop.Before(t) {
+			programs = append(programs, p)
+		}
+	}
+	if len(segments) != len(c.segments) {
+		c.segments = segments
+		if err := c.saveSegments(); err != nil {
+			return err
+		}
+	}
+	if len(programs) != len(c.programs) {
+		c.programs = programs
+		return c.savePrograms()
+	}
+	return nil
+}
+
+func (c *Channel) saveSegments() error {
+	values := make([]interface{}, len(c.segments))
+	for i, s := range c.segments {
+		values[i] = s
+	}
+	return writeJSONL(filepath.Join(c.dir, segmentsFile), values)
+}
+
+func (c *Channel) savePrograms() error {
+	values := make([]interface{}, len(c.programs))
+	for i, p := range c.programs {
+		values[i] = p
+	}
+	return writeJSONL(filepath.Join(c.dir, programsFile), values)
+}
+
+type segmentsByStart []Segment
+
+func (s segmentsByStart) Len() int           { return len(s) }
+func (s segmentsByStart) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s segmentsByStart) Less(i, j int) bool { return s[i].Start.Before(s[j].Start) }
+
+type programsByStart []Program
+
+func (s programsByStart) Len() int           { return len(s) }
+func (s programsByStart) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s programsByStart) Less(i, j int) bool { return s[i].Start.Before(s[j].Start) }
+
+// loadJSONL calls decode for each line of the file name, malformed lines are
+// skipped.
+func loadJSONL(name string, decode func(line []byte) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		if err := decode(s.Bytes()); err != nil {
+			continue
+		}
+	}
+	return s.Err()
+}
+
+func appendJSONL(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeJSONL replaces the file name with values atomically.
+func writeJSONL(name string, values []interface{}) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
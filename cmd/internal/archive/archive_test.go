@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parkghost/hiradio"
+)
+
+var t0 = time.Date(2015, 3, 1, 17, 0, 0, 0, time.Local)
+
+func newTestArchive(t *testing.T) (*Archive, string) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	a, err := Open(dir)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	return a, dir
+}
+
+func TestResolvePrograms(t *testing.T) {
+	list := []hiradio.Program{
+		{StartTime: "17:00", EndTime: "18:00", Name: "週日 HIT DJ"},
+		{StartTime: "23:00", EndTime: "01:00", Name: "Night"},
+		{StartTime: "23:00", EndTime: "24:00", Name: "Late"},
+		{StartTime: "", EndTime: "", Name: "Unknown"},
+	}
+	got := ResolvePrograms(t0, list)
+	want := []Program{
+		{"週日 HIT DJ", t0, t0.Add(1 * time.Hour)},
+		{"Night", t0.Add(6 * time.Hour), t0.Add(8 * time.Hour)},
+		{"Late", t0.Add(6 * time.Hour), t0.Add(7 * time.Hour)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestChannel(t *testing.T) {
+	a, dir := newTestArchive(t)
+	defer os.RemoveAll(dir)
+
+	c, err := a.Channel(222)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	for i := 0; i < 6; i++ {
+		start := t0.Add(time.Duration(i) * 10 * time.Minute)
+		if err := c.Add(start, 10*time.Minute, ".ts", []byte{byte(i)}); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+	err = c.AddPrograms([]Program{
+		{"A", t0, t0.Add(30 * time.Minute)},
+		{"B", t0.Add(30 * time.Minute), t0.Add(60 * time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	// reopen the archive
+	a, err = Open(dir)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if !a.Has(222) || a.Has(88) {
+		t.Fatal("expected only channel 222 in the archive")
+	}
+	if ids, _ := a.ChannelIDs(); !reflect.DeepEqual(ids, []int{222}) {
+		t.Fatalf("got %v, want %v", ids, []int{222})
+	}
+	if c, err = a.Channel(222); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	p, found := c.Program(t0.Add(30 * time.Minute))
+	if !found || p.Name != "B" {
+		t.Fatalf("got %v, want program B", p)
+	}
+	segments := c.Segments(p.Start, p.Stop)
+	if len(segments) != 3 || !segments[0].Start.Equal(p.Start) {
+		t.Fatalf("got %v, want 3 segments from %v", segments, p.Start)
+	}
+	data, err := ioutil.ReadFile(c.Path(segments[0]))
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if data[0] != 3 {
+		t.Fatalf("got %d, want %d", data[0], 3)
+	}
+
+	// replace a program
+	if err := c.AddPrograms([]Program{{"A2", t0, t0.Add(30 * time.Minute)}}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if got := c.Programs(t0, t0.Add(1*time.Hour)); len(got) != 2 || got[0].Name != "A2" {
+		t.Fatalf("got %v, want A2 and B", got)
+	}
+
+	// prune
+	if err := c.Prune(t0.Add(35 * time.Minute)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if got := c.Segments(t0, t0.Add(1*time.Hour)); len(got) != 3 {
+		t.Fatalf("got %d segments, want %d", len(got), 3)
+	}
+	if got := c.Programs(t0, t0.Add(1*time.Hour)); len(got) != 1 || got[0].Name != "B" {
+		t.Fatalf("got %v, want B", got)
+	}
+	if _, err := os.Stat(c.Path(segments[0])); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "222", segmentsDir))
+	if len(files) != 3 {
+		t.Fatalf("got %d segment files, want %d", len(files), 3)
+	}
+}
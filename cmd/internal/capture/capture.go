@@ -0,0 +1,128 @@
+// Package capture tracks the completeness of a live stream captured into a
+// file, gaps of missed segments are detected by media sequence numbers.
+package capture
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+)
+
+// ReportExt is the extension of report files, appended to the name of the
+// captured file.
+const ReportExt = ".capture.json"
+
+// Gap represents missed segments of the stream.
+type Gap struct {
+	// Time is when the gap is detected.
+	Time time.Time `json:"time"`
+	// Offset is the position of the gap in the captured file.
+	Offset time.Duration `json:"offset"`
+	// From and To are the media sequence numbers of the first and last
+	// missed segments, they are -1 if the stream is restarted.
+	From int `json:"from"`
+	To   int `json:"to"`
+	// Duration is the estimated duration of missed segments.
+	Duration time.Duration `json:"duration"`
+}
+
+// Restarted reports whether the gap is caused by a restarted stream.
+func (g Gap) Restarted() bool {
+	return g.From == -1
+}
+
+// Report represents the completeness of a captured stream.
+type Report struct {
+	ChannelID int       `json:"channel_id"`
+	File      string    `json:"file"`
+	Start     time.Time `json:"start"`
+	// Stop is the time of the last segment captured.
+	Stop time.Time `json:"stop"`
+
+	Segments     int           `json:"segments"`
+	LastSequence int           `json:"last_sequence"`
+	Recorded     time.Duration `json:"recorded"`
+	Missed       time.Duration `json:"missed"`
+	Gaps         []Gap         `json:"gaps"`
+}
+
+// Add records the segment captured at now, and returns the gap before the
+// segment if segments are missed.
+func (r *Report) Add(seg hls.Segment, now time.Time) *Gap {
+	var gap *Gap
+	if r.Segments > 0 {
+		switch {
+		case seg.Sequence > r.LastSequence+1:
+			n := seg.Sequence - r.LastSequence - 1
+			gap = &Gap{
+				From:     r.LastSequence + 1,
+				To:       seg.Sequence - 1,
+				Duration: time.Duration(n) * seg.Duration,
+			}
+		case seg.Sequence <= r.LastSequence:
+			// estimate by the time since the last segment
+			d := now.Sub(r.Stop) - seg.Duration
+			if d < 0 {
+				d = 0
+			}
+			gap = &Gap{From: -1, To: -1, Duration: d}
+		}
+	} else {
+		r.Start = now
+	}
+	if gap != nil {
+		gap.Time = now
+		gap.Offset = r.Recorded
+		r.Gaps = append(r.Gaps, *gap)
+		r.Missed += gap.Duration
+	}
+
+	r.Segments++
+	r.LastSequence = seg.Sequence
+	r.Recorded += seg.Duration
+	r.Stop = now
+	return gap
+}
+
+// Completeness returns the ratio of recorded duration to the duration of the
+// stream since the first segment.
+func (r *Report) Completeness() float64 {
+	total := r.Recorded + r.Missed
+	if total == 0 {
+		return 0
+	}
+	return float64(r.Recorded) / float64(total)
+}
+
+// MarshalJSON encodes the report with the completeness.
+func (r *Report) MarshalJSON() ([]byte, error) {
+	type report Report
+	return json.Marshal(struct {
+		*report
+		Completeness float64 `json:"completeness"`
+	}{(*report)(r), r.Completeness()})
+}
+
+// Load reads the report of the captured file.
+func Load(name string) (*Report, error) {
+	data, err := ioutil.ReadFile(name + ReportExt)
+	if err != nil {
+		return nil, err
+	}
+	r := new(Report)
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Save writes the report of the captured file.
+func Save(name string, r *Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name+ReportExt, data, 0644)
+}
@@ -0,0 +1,80 @@
+package capture
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+)
+
+var t0 = time.Date(2015, 3, 1, 17, 0, 0, 0, time.UTC)
+
+func TestReportAdd(t *testing.T) {
+	r := &Report{ChannelID: 222}
+	seg := func(seq int) hls.Segment { return hls.Segment{Sequence: seq, Duration: 10 * time.Second} }
+
+	for i, c := range []struct {
+		seq  int
+		at   time.Duration
+		want *Gap
+	}{
+		{100, 0, nil},
+		{101, 10 * time.Second, nil},
+		{104, 40 * time.Second, &Gap{Time: t0.Add(40 * time.Second), Offset: 20 * time.Second, From: 102, To: 103, Duration: 20 * time.Second}},
+		// restarted stream
+		{1, 100 * time.Second, &Gap{Time: t0.Add(100 * time.Second), Offset: 30 * time.Second, From: -1, To: -1, Duration: 50 * time.Second}},
+		{2, 110 * time.Second, nil},
+	} {
+		got := r.Add(seg(c.seq), t0.Add(c.at))
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%d: got %+v, want %+v", i, got, c.want)
+		}
+	}
+
+	if r.Segments != 5 || r.LastSequence != 2 || r.Recorded != 50*time.Second || r.Missed != 70*time.Second {
+		t.Fatalf("got %+v", r)
+	}
+	if !r.Start.Equal(t0) || !r.Stop.Equal(t0.Add(110*time.Second)) {
+		t.Fatalf("got start %s, stop %s", r.Start, r.Stop)
+	}
+	if got, want := r.Completeness(), 50.0/120; got != want {
+		t.Fatalf("got completeness %v, want %v", got, want)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "222.ts")
+	r := &Report{ChannelID: 222, File: name}
+	r.Add(hls.Segment{Sequence: 1, Duration: 10 * time.Second}, t0)
+	r.Add(hls.Segment{Sequence: 3, Duration: 10 * time.Second}, t0.Add(20*time.Second))
+	if err := Save(name, r); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(name + ReportExt)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if !strings.Contains(string(data), `"completeness": 0.666`) {
+		t.Fatalf("got %s, want completeness", data)
+	}
+
+	got, err := Load(name)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if got.LastSequence != 3 || got.Missed != 10*time.Second || len(got.Gaps) != 1 || !got.Stop.Equal(r.Stop) {
+		t.Fatalf("got %+v, want %+v", got, r)
+	}
+}
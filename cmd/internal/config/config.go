@@ -1,18 +1,32 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 )
 
 type Config struct {
-	data    map[string]interface{}
-	changed bool
+	data      map[string]interface{}
+	changed   bool
+	updated   map[string]bool
+	envPrefix string
 }
 
+// Set sets the value of key. The value is stored in the form of decoded
+// JSON, time.Duration is stored as string.
 func (c *Config) Set(key string, value interface{}) {
+	value = normalize(value)
 	orig, found := c.data[key]
 	if found {
 		if !reflect.DeepEqual(value, orig) {
@@ -22,9 +36,87 @@ func (c *Config) Set(key string, value interface{}) {
 		c.changed = true
 	}
 	c.data[key] = value
+	c.updated[key] = true
+}
+
+// Unset removes key.
+func (c *Config) Unset(key string) {
+	if _, found := c.data[key]; found {
+		delete(c.data, key)
+		c.changed = true
+	}
+	c.updated[key] = true
+}
+
+// Get returns the stored value of key without environment variable
+// overrides.
+func (c *Config) Get(key string) (interface{}, bool) {
+	v, found := c.data[key]
+	return v, found
+}
+
+// Keys returns the sorted keys of stored values.
+func (c *Config) Keys() []string {
+	keys := make([]string, 0, len(c.data))
+	for key := range c.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LookupEnv returns the value of environment variable which overrides key.
+func (c *Config) LookupEnv(key string) (string, bool) {
+	if c.envPrefix == "" {
+		return "", false
+	}
+	return os.LookupEnv(EnvName(c.envPrefix, key))
+}
+
+func normalize(value interface{}) interface{} {
+	if d, ok := value.(time.Duration); ok {
+		return d.String()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return value
+	}
+	return v
+}
+
+// BindEnv enables overriding values by environment variables. The name of
+// environment variable is the key in upper snake case with prefix, e.g.
+// HIRADIO_PROXY_PORT for key proxyPort with prefix HIRADIO. Invalid values of
+// environment variables are ignored.
+func (c *Config) BindEnv(prefix string) {
+	c.envPrefix = prefix
+}
+
+// EnvName returns the name of environment variable for key.
+func EnvName(prefix, key string) string {
+	rs := []rune(key)
+	var buf []rune
+	for i, r := range rs {
+		if i > 0 && unicode.IsUpper(r) &&
+			(unicode.IsLower(rs[i-1]) || (i+1 < len(rs) && unicode.IsLower(rs[i+1]))) {
+			buf = append(buf, '_')
+		}
+		buf = append(buf, unicode.ToUpper(r))
+	}
+	return prefix + "_" + string(buf)
 }
 
 func (c *Config) GetString(key string, defaultValue string) string {
+	if s, found := c.LookupEnv(key); found {
+		return s
+	}
+
 	v, found := c.data[key]
 	if !found {
 		return defaultValue
@@ -37,57 +129,413 @@ func (c *Config) GetString(key string, defaultValue string) string {
 }
 
 func (c *Config) GetInt(key string, defaultValue int) int {
+	if s, found := c.LookupEnv(key); found {
+		if i, err := strconv.Atoi(s); err == nil {
+			return i
+		}
+	}
+
+	v, found := c.data[key]
+	if !found {
+		return defaultValue
+	}
+
+	if i, ok := toInt(v); ok {
+		return i
+	}
+	return defaultValue
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch vi := v.(type) {
+	case json.Number:
+		i, err := strconv.Atoi(string(vi))
+		return i, err == nil
+	case float64:
+		return int(vi), true
+	case int:
+		return vi, true
+	}
+	return 0, false
+}
+
+func (c *Config) GetBool(key string, defaultValue bool) bool {
+	if s, found := c.LookupEnv(key); found {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+
 	v, found := c.data[key]
 	if !found {
 		return defaultValue
 	}
 
-	if vi, ok := v.(float64); ok {
-		return int(vi)
+	if vb, ok := v.(bool); ok {
+		return vb
 	}
 	return defaultValue
 }
 
+// GetDuration returns the duration of key. The value is a string accepted by
+// time.ParseDuration, e.g. "1m30s".
+func (c *Config) GetDuration(key string, defaultValue time.Duration) time.Duration {
+	if s, found := c.LookupEnv(key); found {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d
+		}
+	}
+
+	v, found := c.data[key]
+	if !found {
+		return defaultValue
+	}
+
+	if vs, ok := v.(string); ok {
+		if d, err := time.ParseDuration(vs); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// GetStringSlice returns the string slice of key. The value of environment
+// variable is separated by commas.
+func (c *Config) GetStringSlice(key string, defaultValue []string) []string {
+	if s, found := c.LookupEnv(key); found {
+		return splitList(s)
+	}
+
+	v, found := c.data[key]
+	if !found {
+		return defaultValue
+	}
+
+	vl, ok := v.([]interface{})
+	if !ok {
+		return defaultValue
+	}
+	l := make([]string, 0, len(vl))
+	for _, e := range vl {
+		es, ok := e.(string)
+		if !ok {
+			return defaultValue
+		}
+		l = append(l, es)
+	}
+	return l
+}
+
+// GetIntSlice returns the int slice of key. The value of environment variable
+// is separated by commas.
+func (c *Config) GetIntSlice(key string, defaultValue []int) []int {
+	if s, found := c.LookupEnv(key); found {
+		if l, err := parseIntList(splitList(s)); err == nil {
+			return l
+		}
+	}
+
+	v, found := c.data[key]
+	if !found {
+		return defaultValue
+	}
+
+	vl, ok := v.([]interface{})
+	if !ok {
+		return defaultValue
+	}
+	l := make([]int, 0, len(vl))
+	for _, e := range vl {
+		ei, ok := toInt(e)
+		if !ok {
+			return defaultValue
+		}
+		l = append(l, ei)
+	}
+	return l
+}
+
+// GetStringMap returns the string map of key. The value of environment
+// variable is a JSON object.
+func (c *Config) GetStringMap(key string, defaultValue map[string]string) map[string]string {
+	if s, found := c.LookupEnv(key); found {
+		var m map[string]string
+		if err := json.Unmarshal([]byte(s), &m); err == nil {
+			return m
+		}
+	}
+
+	v, found := c.data[key]
+	if !found {
+		return defaultValue
+	}
+
+	vm, ok := v.(map[string]interface{})
+	if !ok {
+		return defaultValue
+	}
+	m := make(map[string]string, len(vm))
+	for k, e := range vm {
+		es, ok := e.(string)
+		if !ok {
+			return defaultValue
+		}
+		m[k] = es
+	}
+	return m
+}
+
+func splitList(s string) []string {
+	var l []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			l = append(l, e)
+		}
+	}
+	return l
+}
+
+func parseIntList(sl []string) ([]int, error) {
+	l := make([]int, 0, len(sl))
+	for _, s := range sl {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, i)
+	}
+	return l, nil
+}
+
 func New() *Config {
 	c := new(Config)
 	c.data = make(map[string]interface{})
+	c.updated = make(map[string]bool)
 	return c
 }
 
 var ErrEmptyFile = errors.New("empty file")
 
+// CorruptError is returned by From if the configuration file is corrupt.
+type CorruptError struct {
+	Err       error  // error of decoding the configuration file
+	Path      string // path of the corrupt file which is moved aside
+	Recovered bool   // whether the configuration is recovered from backup
+}
+
+func (e *CorruptError) Error() string {
+	if e.Recovered {
+		return fmt.Sprintf("corrupt file moved to %s, recovered from backup: %s", e.Path, e.Err)
+	}
+	return fmt.Sprintf("corrupt file moved to %s: %s", e.Path, e.Err)
+}
+
+// From loads the configuration from file name. A corrupt file is moved aside
+// to name.corrupt and a *CorruptError is returned. The configuration is
+// recovered from the backup file name.bak if possible, in which case it is
+// returned together with the error. The file is locked while recovering.
 func From(name string) (*Config, error) {
-	c := New()
-	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDONLY, 0666)
+	c, err := decodeFile(name)
+	if err == nil || err == ErrEmptyFile {
+		return c, err
+	}
+	if _, ok := err.(*os.PathError); ok {
+		return nil, err
+	}
+
+	// the file may be recovered by another process meanwhile
+	l, lerr := Lock(name)
+	if lerr != nil {
+		return nil, lerr
+	}
+	defer l.Unlock()
+	return load(name)
+}
+
+// Update loads the configuration from file name, calls fn to modify it and
+// saves the changes. The file is locked during the whole update. A missing
+// file is regarded as empty, and a corrupt file is recovered as From does, in
+// which case the *CorruptError is returned together with the configuration.
+// If fn returns an error, the changes are not saved and the configuration is
+// returned with the error.
+func Update(name string, fn func(c *Config) error) (*Config, error) {
+	l, err := Lock(name)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	defer l.Unlock()
+
+	c, loadErr := load(name)
+	switch e := loadErr.(type) {
+	case nil:
+	case *CorruptError:
+		if !e.Recovered {
+			c = New()
+		}
+	default:
+		if loadErr != ErrEmptyFile {
+			return nil, loadErr
+		}
+		c, loadErr = New(), nil
+	}
+
+	if err := fn(c); err != nil {
+		return c, err
+	}
+	if err := save(name, c); err != nil {
+		return c, err
+	}
+	return c, loadErr
+}
+
+// load loads the configuration from file name, the caller must hold the
+// lock of the file.
+func load(name string) (*Config, error) {
+	c, err := decodeFile(name)
+	if err == nil || err == ErrEmptyFile {
+		return c, err
+	}
+	if _, ok := err.(*os.PathError); ok {
+		return nil, err
+	}
 
-	// check content size
-	st, err := f.Stat()
+	// corrupt file
+	corrupt := name + corruptSuffix
+	if rerr := os.Rename(name, corrupt); rerr != nil {
+		return nil, err
+	}
+	bc, berr := decodeFile(name + backupSuffix)
+	if berr != nil {
+		return nil, &CorruptError{Err: err, Path: corrupt}
+	}
+	bc.changed = true
+	for key := range bc.data {
+		bc.updated[key] = true
+	}
+	return bc, &CorruptError{Err: err, Path: corrupt, Recovered: true}
+}
+
+const (
+	backupSuffix  = ".bak"
+	corruptSuffix = ".corrupt"
+)
+
+func decodeFile(name string) (*Config, error) {
+	data, err := ioutil.ReadFile(name)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrEmptyFile
+		}
 		return nil, err
 	}
-	if st.Size() == 0 {
+	return decode(data)
+}
+
+func decode(data []byte) (*Config, error) {
+	if len(data) == 0 {
 		return nil, ErrEmptyFile
 	}
 
-	if err = json.NewDecoder(f).Decode(&c.data); err != nil {
+	c := New()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&c.data); err != nil {
 		return nil, err
 	}
+	if c.data == nil {
+		c.data = make(map[string]interface{})
+	}
 	return c, nil
 }
 
+// SaveTo saves the configuration to file name. The file is locked while
+// saving and the changes of c are merged into the current content of the
+// file, so changes from other processes are kept. The file is replaced
+// atomically and the previous content is kept in name.bak.
 func SaveTo(name string, c *Config) error {
 	if !c.changed {
 		return nil
 	}
 
-	f, err := os.Create(name)
+	l, err := Lock(name)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return save(name, c)
+}
+
+// save saves the configuration as SaveTo does, the caller must hold the lock
+// of the file.
+func save(name string, c *Config) error {
+	if !c.changed {
+		return nil
+	}
+
+	// merge changes into current content
+	data := c.data
+	orig, err := ioutil.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	current, derr := decode(orig)
+	if derr == nil {
+		for key := range c.updated {
+			if v, found := c.data[key]; found {
+				current.data[key] = v
+			} else {
+				delete(current.data, key)
+			}
+		}
+		data = current.data
+	}
+
+	buf, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+
+	// keep the last good file
+	if derr == nil {
+		if err := writeFile(name+backupSuffix, orig); err != nil {
+			return err
+		}
+	}
+	if err := writeFile(name, buf); err != nil {
+		return err
+	}
+
+	c.data = data
+	c.changed = false
+	c.updated = make(map[string]bool)
+	return nil
+}
+
+// writeFile writes data to a temporary file and renames it to name.
+func writeFile(name string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	return json.NewEncoder(f).Encode(&c.data)
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
 }
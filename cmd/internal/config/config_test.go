@@ -2,10 +2,13 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func testfile() (string, error) {
@@ -70,6 +73,178 @@ func TestSetAndGetInt(t *testing.T) {
 	}
 }
 
+func TestSetAndGetStringMap(t *testing.T) {
+	testKey := "players"
+	testValue := map[string]string{"mpv": "mpv --no-video {url}"}
+	file, err := testfile()
+	if err != nil {
+		t.Fatalf("unexpected error on testfile: %s", err)
+	}
+
+	c := New()
+	c.Set(testKey, testValue)
+	err = SaveTo(file, c)
+	if err != nil {
+		t.Fatalf("unexpected error on SaveTo: %s", err)
+	}
+
+	c, err = From(file)
+	if err != nil {
+		t.Fatalf("unexpected error on From: %s", err)
+	}
+
+	got := c.GetStringMap(testKey, nil)
+	if !reflect.DeepEqual(got, testValue) {
+		t.Fatalf("got %v, want %v", got, testValue)
+	}
+
+	c.Set(testKey, map[string]interface{}{"mpv": 1})
+	got = c.GetStringMap(testKey, nil)
+	if got != nil {
+		t.Fatalf("got %v, want nil", got)
+	}
+}
+
+func TestSetAndGetTypedValues(t *testing.T) {
+	file, err := testfile()
+	if err != nil {
+		t.Fatalf("unexpected error on testfile: %s", err)
+	}
+
+	c := New()
+	c.Set("verbose", true)
+	c.Set("timeout", 90*time.Second)
+	c.Set("favorites", []int{222, 156})
+	c.Set("formats", []string{"text", "json"})
+	err = SaveTo(file, c)
+	if err != nil {
+		t.Fatalf("unexpected error on SaveTo: %s", err)
+	}
+
+	c, err = From(file)
+	if err != nil {
+		t.Fatalf("unexpected error on From: %s", err)
+	}
+
+	if got := c.GetBool("verbose", false); got != true {
+		t.Fatalf("got %v, want %v", got, true)
+	}
+	if got := c.GetDuration("timeout", 0); got != 90*time.Second {
+		t.Fatalf("got %s, want %s", got, 90*time.Second)
+	}
+	if got, want := c.GetIntSlice("favorites", nil), []int{222, 156}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := c.GetStringSlice("formats", nil), []string{"text", "json"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// wrong types
+	if got := c.GetBool("timeout", false); got != false {
+		t.Fatalf("got %v, want %v", got, false)
+	}
+	if got := c.GetDuration("verbose", time.Second); got != time.Second {
+		t.Fatalf("got %s, want %s", got, time.Second)
+	}
+	if got := c.GetIntSlice("formats", nil); got != nil {
+		t.Fatalf("got %v, want nil", got)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"player":    "HIRADIO_PLAYER",
+		"proxyPort": "HIRADIO_PROXY_PORT",
+		"channelID": "HIRADIO_CHANNEL_ID",
+		"HTTPProxy": "HIRADIO_HTTP_PROXY",
+	}
+	for key, want := range tests {
+		got := EnvName("HIRADIO", key)
+		if got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestBindEnv(t *testing.T) {
+	c := New()
+	c.Set("proxyPort", 1077)
+	c.Set("player", "vlc")
+
+	for key, value := range map[string]string{
+		"HIRADIO_PROXY_PORT": "8080",
+		"HIRADIO_PLAYER":     "mpv",
+		"HIRADIO_FAVORITES":  "222, 156",
+		"HIRADIO_PLAYERS":    `{"mpv":"mpv {url}"}`,
+		"HIRADIO_VERBOSE":    "not bool",
+	} {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+
+	// environment variables are ignored before binding
+	if got := c.GetInt("proxyPort", -1); got != 1077 {
+		t.Fatalf("got %d, want %d", got, 1077)
+	}
+
+	c.BindEnv("HIRADIO")
+	if got := c.GetInt("proxyPort", -1); got != 8080 {
+		t.Fatalf("got %d, want %d", got, 8080)
+	}
+	if got := c.GetString("player", ""); got != "mpv" {
+		t.Fatalf("got %s, want %s", got, "mpv")
+	}
+	if got, want := c.GetIntSlice("favorites", nil), []int{222, 156}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := c.GetStringMap("players", nil), map[string]string{"mpv": "mpv {url}"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := c.GetBool("verbose", true); got != true {
+		t.Fatalf("got %v, want %v", got, true)
+	}
+}
+
+func TestUnset(t *testing.T) {
+	file, err := testfile()
+	if err != nil {
+		t.Fatalf("unexpected error on testfile: %s", err)
+	}
+
+	c := New()
+	c.Set("player", "mpv")
+	c.Set("proxyPort", 1077)
+	if err := SaveTo(file, c); err != nil {
+		t.Fatalf("unexpected error on SaveTo: %s", err)
+	}
+
+	c, err = From(file)
+	if err != nil {
+		t.Fatalf("unexpected error on From: %s", err)
+	}
+	if got, want := c.Keys(), []string{"player", "proxyPort"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	c.Unset("player")
+	if !c.changed {
+		t.Fatal("unset existed key, config.changed should be true")
+	}
+	if err := SaveTo(file, c); err != nil {
+		t.Fatalf("unexpected error on SaveTo: %s", err)
+	}
+
+	c, err = From(file)
+	if err != nil {
+		t.Fatalf("unexpected error on From: %s", err)
+	}
+	if _, found := c.Get("player"); found {
+		t.Fatal("player should be removed")
+	}
+	if v, found := c.Get("proxyPort"); !found || v != json.Number("1077") {
+		t.Fatalf("got %#v, want %#v", v, json.Number("1077"))
+	}
+}
+
 func TestGetStringDefaultValue(t *testing.T) {
 	testKey := "player"
 	testValue := "/usr/bin/vlc"
@@ -145,8 +320,243 @@ func TestFromError(t *testing.T) {
 		t.Fatalf("unexpected error on WriteFile: %s", err)
 	}
 	_, err = From(file)
-	if _, ok := err.(*json.SyntaxError); !ok {
-		t.Fatalf("expected json.SyntaxError when parsing bad format of data: %#+v", err)
+	ce, ok := err.(*CorruptError)
+	if !ok {
+		t.Fatalf("expected CorruptError when parsing bad format of data: %#+v", err)
+	}
+	if _, ok := ce.Err.(*json.SyntaxError); !ok {
+		t.Fatalf("expected json.SyntaxError when parsing bad format of data: %#+v", ce.Err)
+	}
+	if ce.Recovered {
+		t.Fatal("recovered without backup")
+	}
+	data, err := ioutil.ReadFile(ce.Path)
+	if err != nil || string(data) != "bad format" {
+		t.Fatalf("corrupt file should be moved to %s: %s", ce.Path, err)
+	}
+}
+
+func TestFromRecover(t *testing.T) {
+	file, err := testfile()
+	if err != nil {
+		t.Fatalf("unexpected error on testfile: %s", err)
+	}
+
+	c := New()
+	c.Set("player", "mpv")
+	if err := SaveTo(file, c); err != nil {
+		t.Fatalf("unexpected error on SaveTo: %s", err)
+	}
+	c.Set("player", "vlc")
+	if err := SaveTo(file, c); err != nil {
+		t.Fatalf("unexpected error on SaveTo: %s", err)
+	}
+
+	// simulate a crash during writing
+	if err := ioutil.WriteFile(file, []byte(`{"player": "vl`), 0644); err != nil {
+		t.Fatalf("unexpected error on WriteFile: %s", err)
+	}
+
+	c, err = From(file)
+	ce, ok := err.(*CorruptError)
+	if !ok || !ce.Recovered {
+		t.Fatalf("expected recovered CorruptError: %#+v", err)
+	}
+	if got := c.GetString("player", ""); got != "mpv" {
+		t.Fatalf("got %s, want %s", got, "mpv")
+	}
+
+	// recovered configuration is saved back
+	if err := SaveTo(file, c); err != nil {
+		t.Fatalf("unexpected error on SaveTo: %s", err)
+	}
+	c, err = From(file)
+	if err != nil {
+		t.Fatalf("unexpected error on From: %s", err)
+	}
+	if got := c.GetString("player", ""); got != "mpv" {
+		t.Fatalf("got %s, want %s", got, "mpv")
+	}
+}
+
+func TestSaveToMerge(t *testing.T) {
+	file, err := testfile()
+	if err != nil {
+		t.Fatalf("unexpected error on testfile: %s", err)
+	}
+
+	// two processes load the same file
+	c1 := New()
+	c2 := New()
+	c1.Set("player", "mpv")
+	c2.Set("proxyPort", 8080)
+	if err := SaveTo(file, c1); err != nil {
+		t.Fatalf("unexpected error on SaveTo: %s", err)
+	}
+	if err := SaveTo(file, c2); err != nil {
+		t.Fatalf("unexpected error on SaveTo: %s", err)
+	}
+
+	c, err := From(file)
+	if err != nil {
+		t.Fatalf("unexpected error on From: %s", err)
+	}
+	if got := c.GetString("player", ""); got != "mpv" {
+		t.Fatalf("got %s, want %s", got, "mpv")
+	}
+	if got := c.GetInt("proxyPort", -1); got != 8080 {
+		t.Fatalf("got %d, want %d", got, 8080)
+	}
+
+	// no temporary or lock files are left
+	files, err := filepath.Glob(file + ".*")
+	if err != nil {
+		t.Fatalf("unexpected error on Glob: %s", err)
+	}
+	if len(files) != 1 || files[0] != file+".bak" {
+		t.Fatalf("unexpected files: %v", files)
+	}
+}
+
+func TestLock(t *testing.T) {
+	file, err := testfile()
+	if err != nil {
+		t.Fatalf("unexpected error on testfile: %s", err)
+	}
+
+	l, err := Lock(file)
+	if err != nil {
+		t.Fatalf("unexpected error on Lock: %s", err)
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		l2, err := Lock(file)
+		if err != nil {
+			t.Errorf("unexpected error on Lock: %s", err)
+			close(locked)
+			return
+		}
+		close(locked)
+		l2.Unlock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("lock should be held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatalf("unexpected error on Unlock: %s", err)
+	}
+	<-locked
+
+	// stale lock
+	if err := ioutil.WriteFile(file+lockSuffix, nil, 0644); err != nil {
+		t.Fatalf("unexpected error on WriteFile: %s", err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(file+lockSuffix, old, old); err != nil {
+		t.Fatalf("unexpected error on Chtimes: %s", err)
+	}
+	l, err = Lock(file)
+	if err != nil {
+		t.Fatalf("unexpected error on Lock: %s", err)
+	}
+
+	// a fresh lock is not removed by breaking stale locks
+	if breakStaleLock(file + lockSuffix) {
+		t.Fatal("fresh lock should not be removed")
+	}
+	if _, err := os.Stat(file + lockSuffix); err != nil {
+		t.Fatalf("lock should be kept: %s", err)
+	}
+	l.Unlock()
+
+	// stale lock being broken by a crashed process
+	for _, name := range []string{file + lockSuffix, file + lockSuffix + breakSuffix} {
+		if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+			t.Fatalf("unexpected error on WriteFile: %s", err)
+		}
+		if err := os.Chtimes(name, old, old); err != nil {
+			t.Fatalf("unexpected error on Chtimes: %s", err)
+		}
+	}
+	l, err = Lock(file)
+	if err != nil {
+		t.Fatalf("unexpected error on Lock: %s", err)
+	}
+	l.Unlock()
+	if _, err := os.Stat(file + lockSuffix + breakSuffix); !os.IsNotExist(err) {
+		t.Fatalf("break lock should be removed: %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	file, err := testfile()
+	if err != nil {
+		t.Fatalf("unexpected error on testfile: %s", err)
+	}
+
+	c, err := Update(file, func(c *Config) error {
+		if _, err := os.Stat(file + lockSuffix); err != nil {
+			t.Errorf("file should be locked: %s", err)
+		}
+		c.Set("proxyPort", 8080)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error on Update: %s", err)
+	}
+	if got := c.GetInt("proxyPort", -1); got != 8080 {
+		t.Fatalf("got %d, want %d", got, 8080)
+	}
+
+	// changes are not saved on errors
+	errUpdate := errors.New("update failed")
+	if _, err := Update(file, func(c *Config) error {
+		c.Set("proxyPort", 1077)
+		return errUpdate
+	}); err != errUpdate {
+		t.Fatalf("got %v, want %v", err, errUpdate)
+	}
+	c, err = From(file)
+	if err != nil {
+		t.Fatalf("unexpected error on From: %s", err)
+	}
+	if got := c.GetInt("proxyPort", -1); got != 8080 {
+		t.Fatalf("got %d, want %d", got, 8080)
+	}
+
+	// corrupt file is recovered from backup
+	if _, err := Update(file, func(c *Config) error {
+		c.Set("timeout", "30s")
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error on Update: %s", err)
+	}
+	if err := ioutil.WriteFile(file, []byte("bad format"), 0644); err != nil {
+		t.Fatalf("unexpected error on WriteFile: %s", err)
+	}
+	c, err = Update(file, func(c *Config) error {
+		c.Set("player", "mpv")
+		return nil
+	})
+	if ce, ok := err.(*CorruptError); !ok || !ce.Recovered {
+		t.Fatalf("expected recovered CorruptError on Update: %#+v", err)
+	}
+	c, err = From(file)
+	if err != nil {
+		t.Fatalf("unexpected error on From: %s", err)
+	}
+	if got := c.GetString("player", ""); got != "mpv" {
+		t.Fatalf("got %q, want %q", got, "mpv")
+	}
+	if got := c.GetInt("proxyPort", -1); got != 8080 {
+		t.Fatalf("got %d, want %d", got, 8080)
+	}
+	if _, err := os.Stat(file + lockSuffix); !os.IsNotExist(err) {
+		t.Fatalf("lock should be released: %v", err)
 	}
 }
 
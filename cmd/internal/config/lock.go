@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	lockSuffix  = ".lock"
+	breakSuffix = ".break"
+
+	// lockTimeout is the maximum time to wait for the lock.
+	lockTimeout = 5 * time.Second
+	// lockRetryInterval is the interval between attempts to acquire the lock.
+	lockRetryInterval = 10 * time.Millisecond
+	// staleLockAge is the age of lock file regarded as left by a crashed
+	// process.
+	staleLockAge = 30 * time.Second
+)
+
+var ErrLockTimeout = errors.New("timeout on acquiring lock")
+
+// FileLock is an advisory lock of the configuration file. It is a lock file
+// named name.lock next to the configuration file, which is created
+// exclusively.
+type FileLock struct {
+	name string
+}
+
+// Lock acquires the lock of the configuration file name. A lock file older
+// than 30 seconds is regarded as stale and removed.
+//
+// Removing a stale lock is guarded by another lock file name.lock.break, so
+// a lock acquired by another process after the stale one is never removed.
+func Lock(name string) (*FileLock, error) {
+	lockName := name + lockSuffix
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return &FileLock{lockName}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if breakStaleLock(lockName) {
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLockTimeout
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	return os.Remove(l.name)
+}
+
+// breakStaleLock removes the lock file if it is stale and reports whether it
+// is removed. The lock file is checked and removed while holding the break
+// lock, which is regarded as stale as the lock file does.
+func breakStaleLock(lockName string) bool {
+	breakName := lockName + breakSuffix
+	f, err := os.OpenFile(breakName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		if isStale(breakName) {
+			os.Remove(breakName)
+		}
+		return false
+	}
+	f.Close()
+	defer os.Remove(breakName)
+
+	if !isStale(lockName) {
+		return false
+	}
+	return os.Remove(lockName) == nil
+}
+
+// isStale reports whether the lock file is older than staleLockAge.
+func isStale(name string) bool {
+	st, err := os.Stat(name)
+	return err == nil && time.Since(st.ModTime()) > staleLockAge
+}
@@ -0,0 +1,182 @@
+// Package history records listening sessions of hiradio.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+)
+
+// Session represents a listening session of a channel.
+type Session struct {
+	ChannelID int       `json:"channel_id"`
+	Title     string    `json:"channel_title"`
+	Type      string    `json:"channel_type"`
+	Start     time.Time `json:"start"`
+	Stop      time.Time `json:"stop"`
+	Programs  []Program `json:"programs,omitempty"`
+}
+
+// Program represents a program heard in a session.
+type Program struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+}
+
+// Duration returns the listening time of the session.
+func (s Session) Duration() time.Duration {
+	return s.Stop.Sub(s.Start)
+}
+
+// ProgramDurations returns the listening time of each program heard in the
+// session. A program lasts until the next program starts or the session stops.
+func (s Session) ProgramDurations() map[string]time.Duration {
+	m := make(map[string]time.Duration)
+	for i, p := range s.Programs {
+		end := s.Stop
+		if i+1 < len(s.Programs) {
+			end = s.Programs[i+1].Start
+		}
+		if p.Start.Before(s.Start) {
+			p.Start = s.Start
+		}
+		if d := end.Sub(p.Start); d > 0 {
+			m[p.Name] += d
+		}
+	}
+	return m
+}
+
+// Append appends the session to the history file name.
+func Append(name string, s Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	// a single write of a line is not interleaved with other processes
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load loads sessions from the history file name in order of recording.
+// Malformed lines are skipped.
+func Load(name string) ([]Session, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var sessions []Session
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var s Session
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, scanner.Err()
+}
+
+// Stat represents the total listening time of a group.
+type Stat struct {
+	Key      string        `json:"key"`
+	Duration time.Duration `json:"duration"`
+	Sessions int           `json:"sessions"`
+}
+
+// Grouping of statistics.
+const (
+	ByChannel = "channel"
+	ByType    = "type"
+	ByProgram = "program"
+)
+
+// Stats returns the total listening time grouped by channel, type or program,
+// in descending order of time.
+func Stats(sessions []Session, by string) []Stat {
+	m := make(map[string]*Stat)
+	add := func(key string, d time.Duration) {
+		st, found := m[key]
+		if !found {
+			st = &Stat{Key: key}
+			m[key] = st
+		}
+		st.Duration += d
+		st.Sessions++
+	}
+
+	for _, s := range sessions {
+		switch by {
+		case ByChannel:
+			add(s.Title, s.Duration())
+		case ByType:
+			add(s.Type, s.Duration())
+		case ByProgram:
+			for name, d := range s.ProgramDurations() {
+				add(name, d)
+			}
+		}
+	}
+
+	stats := make([]Stat, 0, len(m))
+	for _, st := range m {
+		stats = append(stats, *st)
+	}
+	sort.Sort(byDuration(stats))
+	return stats
+}
+
+type byDuration []Stat
+
+func (s byDuration) Len() int      { return len(s) }
+func (s byDuration) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byDuration) Less(i, j int) bool {
+	if s[i].Duration != s[j].Duration {
+		return s[i].Duration > s[j].Duration
+	}
+	return s[i].Key < s[j].Key
+}
+
+// Since returns the sessions started after t.
+func Since(sessions []Session, t time.Time) []Session {
+	var list []Session
+	for _, s := range sessions {
+		if !s.Start.Before(t) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// Recent returns the latest session of each channel, most recent first, at
+// most n sessions.
+func Recent(sessions []Session, n int) []Session {
+	var list []Session
+	seen := make(map[int]bool)
+	for i := len(sessions) - 1; i >= 0 && len(list) < n; i-- {
+		s := sessions[i]
+		if seen[s.ChannelID] {
+			continue
+		}
+		seen[s.ChannelID] = true
+		list = append(list, s)
+	}
+	return list
+}
@@ -0,0 +1,129 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testfile() (string, error) {
+	dir, err := ioutil.TempDir("", "hiradio")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+var base = time.Date(2015, 3, 1, 17, 30, 0, 0, time.UTC)
+
+var testSessions = []Session{
+	{
+		ChannelID: 222, Title: "HitFm", Type: "音樂",
+		Start: base, Stop: base.Add(90 * time.Minute),
+		Programs: []Program{
+			{"週日 HIT DJ", base.Add(-30 * time.Minute)},
+			{"HITO唱片行", base.Add(30 * time.Minute)},
+		},
+	},
+	{
+		ChannelID: 232, Title: "飛碟電台", Type: "綜合",
+		Start: base.Add(2 * time.Hour), Stop: base.Add(3 * time.Hour),
+		Programs: []Program{
+			{"飛碟晚餐", base.Add(2 * time.Hour)},
+		},
+	},
+	{
+		ChannelID: 222, Title: "HitFm", Type: "音樂",
+		Start: base.Add(24 * time.Hour), Stop: base.Add(24*time.Hour + 10*time.Minute),
+	},
+}
+
+func TestAppendAndLoad(t *testing.T) {
+	file, err := testfile()
+	if err != nil {
+		t.Fatalf("unexpected error on testfile: %s", err)
+	}
+
+	got, err := Load(file)
+	if err != nil || got != nil {
+		t.Fatalf("expected empty history: %v, %s", got, err)
+	}
+
+	for _, s := range testSessions {
+		if err := Append(file, s); err != nil {
+			t.Fatalf("unexpected error on Append: %s", err)
+		}
+	}
+	// malformed lines are skipped
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("unexpected error on OpenFile: %s", err)
+	}
+	f.WriteString("{\"channel_id\": 2\n")
+	f.Close()
+
+	got, err = Load(file)
+	if err != nil {
+		t.Fatalf("unexpected error on Load: %s", err)
+	}
+	if !reflect.DeepEqual(got, testSessions) {
+		t.Fatalf("got %+v, want %+v", got, testSessions)
+	}
+}
+
+func TestProgramDurations(t *testing.T) {
+	got := testSessions[0].ProgramDurations()
+	want := map[string]time.Duration{
+		"週日 HIT DJ": 30 * time.Minute,
+		"HITO唱片行":   60 * time.Minute,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		by   string
+		want []Stat
+	}{
+		{ByChannel, []Stat{{"HitFm", 100 * time.Minute, 2}, {"飛碟電台", 60 * time.Minute, 1}}},
+		{ByType, []Stat{{"音樂", 100 * time.Minute, 2}, {"綜合", 60 * time.Minute, 1}}},
+		{ByProgram, []Stat{
+			{"HITO唱片行", 60 * time.Minute, 1},
+			{"飛碟晚餐", 60 * time.Minute, 1},
+			{"週日 HIT DJ", 30 * time.Minute, 1},
+		}},
+	}
+	for _, test := range tests {
+		got := Stats(testSessions, test.by)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("Stats(%s): got %+v, want %+v", test.by, got, test.want)
+		}
+	}
+}
+
+func TestRecent(t *testing.T) {
+	got := Recent(testSessions, 5)
+	want := []Session{testSessions[2], testSessions[1]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	got = Recent(testSessions, 1)
+	want = []Session{testSessions[2]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSince(t *testing.T) {
+	got := Since(testSessions, base.Add(time.Hour))
+	want := testSessions[1:]
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
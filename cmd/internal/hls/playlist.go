@@ -0,0 +1,232 @@
+// Package hls reads and writes HTTP Live Streaming playlists and downloads
+// segments of live streams.
+//
+// Spec: https://tools.ietf.org/html/draft-pantos-http-live-streaming
+package hls
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidPlaylist is returned if the input is not an m3u8 playlist.
+var ErrInvalidPlaylist = errors.New("invalid playlist")
+
+// Segment represents a media segment.
+type Segment struct {
+	URI           string
+	Duration      time.Duration
+	Sequence      int
+	Title         string
+	Discontinuity bool
+}
+
+// Variant represents a stream in a master playlist.
+type Variant struct {
+	URI       string
+	Bandwidth int
+	Codecs    string
+}
+
+// Playlist represents a master playlist if Variants is not empty, otherwise a
+// media playlist.
+type Playlist struct {
+	Variants []Variant
+
+	Type                  string // EVENT or VOD, empty for live
+	TargetDuration        time.Duration
+	MediaSequence         int
+	DiscontinuitySequence int
+	Segments              []Segment
+	EndList               bool
+}
+
+// Master reports whether p is a master playlist.
+func (p *Playlist) Master() bool {
+	return len(p.Variants) > 0
+}
+
+// Duration returns the total duration of segments.
+func (p *Playlist) Duration() time.Duration {
+	var d time.Duration
+	for _, s := range p.Segments {
+		d += s.Duration
+	}
+	return d
+}
+
+// Parse parses a playlist from r.
+func Parse(r io.Reader) (*Playlist, error) {
+	p := new(Playlist)
+	s := bufio.NewScanner(r)
+	var (
+		header  bool
+		seg     Segment
+		variant *Variant
+	)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if !header {
+			if line != "#EXTM3U" {
+				return nil, ErrInvalidPlaylist
+			}
+			header = true
+			continue
+		}
+
+		tag, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 && strings.HasPrefix(line, "#") {
+			tag, value = line[:i], line[i+1:]
+		}
+		var err error
+		switch tag {
+		case "#EXT-X-TARGETDURATION":
+			var n int
+			n, err = strconv.Atoi(value)
+			p.TargetDuration = time.Duration(n) * time.Second
+		case "#EXT-X-MEDIA-SEQUENCE":
+			p.MediaSequence, err = strconv.Atoi(value)
+		case "#EXT-X-DISCONTINUITY-SEQUENCE":
+			p.DiscontinuitySequence, err = strconv.Atoi(value)
+		case "#EXT-X-DISCONTINUITY":
+			seg.Discontinuity = true
+		case "#EXT-X-PLAYLIST-TYPE":
+			p.Type = value
+		case "#EXT-X-ENDLIST":
+			p.EndList = true
+		case "#EXTINF":
+			duration, title := value, ""
+			if i := strings.Index(value, ","); i >= 0 {
+				duration, title = value[:i], value[i+1:]
+			}
+			var sec float64
+			sec, err = strconv.ParseFloat(duration, 64)
+			seg.Duration = time.Duration(sec * float64(time.Second))
+			seg.Title = title
+		case "#EXT-X-STREAM-INF":
+			attrs := parseAttributes(value)
+			variant = &Variant{Codecs: attrs["CODECS"]}
+			if v, found := attrs["BANDWIDTH"]; found {
+				variant.Bandwidth, err = strconv.Atoi(v)
+			}
+		default:
+			if strings.HasPrefix(line, "#") {
+				// unsupported tags and comments
+				continue
+			}
+			if variant != nil {
+				variant.URI = line
+				p.Variants = append(p.Variants, *variant)
+				variant = nil
+				continue
+			}
+			seg.URI = line
+			seg.Sequence = p.MediaSequence + len(p.Segments)
+			p.Segments = append(p.Segments, seg)
+			seg = Segment{}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", tag, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if !header {
+		return nil, ErrInvalidPlaylist
+	}
+	return p, nil
+}
+
+// parseAttributes parses an attribute list, e.g. BANDWIDTH=64000,CODECS="a,b".
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		i := strings.Index(s, "=")
+		if i < 0 {
+			break
+		}
+		name := strings.TrimSpace(s[:i])
+		s = s[i+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			s = s[1:]
+			end := strings.Index(s, `"`)
+			if end < 0 {
+				end = len(s)
+			}
+			value = s[:end]
+			s = strings.TrimPrefix(s[end:], `"`)
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				end = len(s)
+			}
+			value = s[:end]
+			s = s[end:]
+		}
+		attrs[name] = value
+		s = strings.TrimPrefix(s, ",")
+	}
+	return attrs
+}
+
+// Encode writes the playlist to w in m3u8 format.
+func (p *Playlist) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	if p.Master() {
+		for _, v := range p.Variants {
+			fmt.Fprintf(bw, "#EXT-X-STREAM-INF:BANDWIDTH=%d", v.Bandwidth)
+			if v.Codecs != "" {
+				fmt.Fprintf(bw, ",CODECS=%q", v.Codecs)
+			}
+			fmt.Fprintf(bw, "\n%s\n", v.URI)
+		}
+		return bw.Flush()
+	}
+
+	fmt.Fprintln(bw, "#EXT-X-VERSION:3")
+	if p.Type != "" {
+		fmt.Fprintf(bw, "#EXT-X-PLAYLIST-TYPE:%s\n", p.Type)
+	}
+	fmt.Fprintf(bw, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(p.TargetDuration.Seconds())))
+	fmt.Fprintf(bw, "#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence)
+	if p.DiscontinuitySequence > 0 {
+		fmt.Fprintf(bw, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", p.DiscontinuitySequence)
+	}
+	for _, s := range p.Segments {
+		if s.Discontinuity {
+			fmt.Fprintln(bw, "#EXT-X-DISCONTINUITY")
+		}
+		fmt.Fprintf(bw, "#EXTINF:%.3f,%s\n%s\n", s.Duration.Seconds(), s.Title, s.URI)
+	}
+	if p.EndList {
+		fmt.Fprintln(bw, "#EXT-X-ENDLIST")
+	}
+	return bw.Flush()
+}
+
+// ResolveURL resolves the URI in a playlist against the playlist URL base.
+func ResolveURL(base, uri string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(u).String(), nil
+}
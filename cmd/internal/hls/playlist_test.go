@@ -0,0 +1,119 @@
+package hls
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mediaPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:120
+#EXTINF:10.000,
+media_120.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:9.500,HitFm
+media_121.ts
+`
+
+const masterPlaylist = `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=64000,CODECS="mp4a.40.2,mp4a.40.5"
+chunklist_64k.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=128000
+chunklist_128k.m3u8
+`
+
+func TestParseMedia(t *testing.T) {
+	p, err := Parse(strings.NewReader(mediaPlaylist))
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := &Playlist{
+		TargetDuration: 10 * time.Second,
+		MediaSequence:  120,
+		Segments: []Segment{
+			{URI: "media_120.ts", Duration: 10 * time.Second, Sequence: 120},
+			{URI: "media_121.ts", Duration: 9500 * time.Millisecond, Sequence: 121, Title: "HitFm", Discontinuity: true},
+		},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Fatalf("got %+v, want %+v", p, want)
+	}
+	if p.Master() {
+		t.Fatal("expected media playlist")
+	}
+	if got, want := p.Duration(), 19500*time.Millisecond; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseMaster(t *testing.T) {
+	p, err := Parse(strings.NewReader(masterPlaylist))
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := []Variant{
+		{URI: "chunklist_64k.m3u8", Bandwidth: 64000, Codecs: "mp4a.40.2,mp4a.40.5"},
+		{URI: "chunklist_128k.m3u8", Bandwidth: 128000},
+	}
+	if !reflect.DeepEqual(p.Variants, want) {
+		t.Fatalf("got %+v, want %+v", p.Variants, want)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cases := []string{
+		"",
+		"<html></html>",
+		"#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:x\n",
+		"#EXTM3U\n#EXTINF:abc,\nmedia.ts\n",
+	}
+	for _, c := range cases {
+		if _, err := Parse(strings.NewReader(c)); err == nil {
+			t.Errorf("Parse(%q): expected error", c)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	p, err := Parse(strings.NewReader(mediaPlaylist))
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	p.Type = "VOD"
+	p.EndList = true
+
+	var buf bytes.Buffer
+	if err := p.Encode(&buf); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	got, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Fatalf("got %+v, want %+v", got, p)
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	cases := []struct {
+		base, uri, want string
+	}{
+		{"http://example.com/live/playlist.m3u8", "media_1.ts", "http://example.com/live/media_1.ts"},
+		{"http://example.com/live/playlist.m3u8", "/vod/media_1.ts", "http://example.com/vod/media_1.ts"},
+		{"http://example.com/live/playlist.m3u8", "http://cdn.example.com/media_1.ts", "http://cdn.example.com/media_1.ts"},
+	}
+	for _, c := range cases {
+		got, err := ResolveURL(c.base, c.uri)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if got != c.want {
+			t.Errorf("got %s, want %s", got, c.want)
+		}
+	}
+}
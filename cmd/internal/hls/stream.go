@@ -0,0 +1,205 @@
+package hls
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// minPollInterval is the minimum interval between playlist reloads.
+const minPollInterval = 1 * time.Second
+
+// StatusError is returned if the server responds with an unexpected status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("GET %s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Get fetches the resource at url.
+func Get(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{url, resp.StatusCode}
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Stream downloads segments of a live stream in order of media sequence
+// number.
+type Stream struct {
+	Client *http.Client
+
+	// URL returns the URL of the playlist, a master playlist is resolved to
+	// the variant of highest bandwidth.
+	URL func() (string, error)
+
+	// Sequence is the media sequence number of the last segment handled.
+	Sequence int
+
+	started  bool
+	mediaURL string
+}
+
+// Run reloads the playlist and calls handle with new segments until stop is
+// closed, the playlist is ended or an error occurs. All segments in the first
+// playlist are handled. Run may be called again after an error to resume from
+// the last segment.
+//
+// Segments which are removed from the server (410, or 404 after they have
+// left the playlist) are skipped, so the media sequence numbers handled are
+// not contiguous. Other 404s are returned as the playlist URL may be
+// expired. If the stream is restarted with lower media sequence numbers, all
+// segments in the playlist are handled as the first playlist.
+func (s *Stream) Run(stop <-chan struct{}, handle func(seg Segment, data []byte) error) error {
+	for {
+		p, err := s.Playlist()
+		if err != nil {
+			return err
+		}
+		if n := len(p.Segments); s.started && n > 0 && p.Segments[n-1].Sequence < s.Sequence {
+			s.started = false
+		}
+
+		for _, seg := range p.Segments {
+			if s.started && seg.Sequence <= s.Sequence {
+				continue
+			}
+			select {
+			case <-stop:
+				return nil
+			default:
+			}
+			u, err := ResolveURL(s.mediaURL, seg.URI)
+			if err != nil {
+				return err
+			}
+			data, err := Get(s.Client, u)
+			if err != nil && s.isRemoved(err, seg) {
+				s.Sequence = seg.Sequence
+				s.started = true
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := handle(seg, data); err != nil {
+				return err
+			}
+			s.Sequence = seg.Sequence
+			s.started = true
+		}
+		if p.EndList {
+			return nil
+		}
+
+		// reload the playlist in half of target duration
+		interval := p.TargetDuration / 2
+		if interval < minPollInterval {
+			interval = minPollInterval
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Playlist fetches the media playlist of the stream.
+func (s *Stream) Playlist() (*Playlist, error) {
+	if s.mediaURL == "" {
+		u, err := s.URL()
+		if err != nil {
+			return nil, err
+		}
+		s.mediaURL = u
+	}
+
+	p, err := s.fetch(s.mediaURL)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Master() {
+		return p, nil
+	}
+
+	best := p.Variants[0]
+	for _, v := range p.Variants[1:] {
+		if v.Bandwidth > best.Bandwidth {
+			best = v
+		}
+	}
+	u, err := ResolveURL(s.mediaURL, best.URI)
+	if err != nil {
+		return nil, err
+	}
+	if p, err = s.fetch(u); err != nil {
+		return nil, err
+	}
+	if p.Master() {
+		return nil, fmt.Errorf("nested master playlist: %s", u)
+	}
+	s.mediaURL = u
+	return p, nil
+}
+
+// Resume makes the stream handle segments after the media sequence number,
+// e.g. the last segment handled by a previous run.
+func (s *Stream) Resume(sequence int) {
+	s.Sequence = sequence
+	s.started = true
+}
+
+// Reset makes the stream fetch the playlist URL again on next reload, e.g. the
+// playlist is expired.
+func (s *Stream) Reset() {
+	s.mediaURL = ""
+}
+
+// isRemoved reports whether err is a response of a segment removed from the
+// server. A segment not found is removed only if it is no longer in the
+// reloaded playlist.
+func (s *Stream) isRemoved(err error, seg Segment) bool {
+	e, ok := err.(*StatusError)
+	if !ok {
+		return false
+	}
+	switch e.StatusCode {
+	case http.StatusGone:
+		return true
+	case http.StatusNotFound:
+		p, err := s.fetch(s.mediaURL)
+		if err != nil {
+			return false
+		}
+		for _, listed := range p.Segments {
+			if listed.Sequence == seg.Sequence {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (s *Stream) fetch(url string) (*Playlist, error) {
+	data, err := Get(s.Client, url)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", url, err)
+	}
+	return p, nil
+}
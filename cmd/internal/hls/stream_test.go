@@ -0,0 +1,164 @@
+package hls
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// liveServer serves a master playlist at /playlist.m3u8 and a media playlist
+// of 3 segments which slides forward on every reload. Segments in missing
+// are responded with the status code.
+type liveServer struct {
+	mu       sync.Mutex
+	sequence int
+	end      int
+	missing  map[int]int
+}
+
+func (s *liveServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.URL.Path {
+	case "/playlist.m3u8":
+		fmt.Fprint(rw, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=64000\nlive/chunklist.m3u8\n")
+	case "/live/chunklist.m3u8":
+		fmt.Fprintf(rw, "#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:%d\n", s.sequence)
+		for i := s.sequence; i < s.sequence+3; i++ {
+			fmt.Fprintf(rw, "#EXTINF:1.0,\nmedia_%d.ts\n", i)
+		}
+		if s.sequence+3 >= s.end {
+			fmt.Fprint(rw, "#EXT-X-ENDLIST\n")
+		} else {
+			s.sequence++
+		}
+	default:
+		var n int
+		if _, err := fmt.Sscanf(req.URL.Path, "/live/media_%d.ts", &n); err != nil {
+			http.NotFound(rw, req)
+			return
+		}
+		if code := s.missing[n]; code != 0 {
+			http.Error(rw, http.StatusText(code), code)
+			return
+		}
+		fmt.Fprintf(rw, "segment %d", n)
+	}
+}
+
+func TestStreamRun(t *testing.T) {
+	ts := httptest.NewServer(&liveServer{sequence: 10, end: 15})
+	defer ts.Close()
+
+	s := &Stream{
+		Client: http.DefaultClient,
+		URL:    func() (string, error) { return ts.URL + "/playlist.m3u8", nil },
+	}
+	var got []string
+	err := s.Run(nil, func(seg Segment, data []byte) error {
+		got = append(got, string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := []string{"segment 10", "segment 11", "segment 12", "segment 13", "segment 14"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if s.Sequence != 14 {
+		t.Fatalf("got %d, want %d", s.Sequence, 14)
+	}
+}
+
+func TestStreamStatusError(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	s := &Stream{
+		Client: http.DefaultClient,
+		URL:    func() (string, error) { return ts.URL + "/playlist.m3u8", nil },
+	}
+	err := s.Run(nil, func(seg Segment, data []byte) error { return nil })
+	if se, ok := err.(*StatusError); !ok || se.StatusCode != http.StatusNotFound {
+		t.Fatalf("got %v, want *StatusError with 404", err)
+	}
+}
+
+func runStream(t *testing.T, s *Stream) []string {
+	var got []string
+	err := s.Run(nil, func(seg Segment, data []byte) error {
+		got = append(got, string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	return got
+}
+
+func TestStreamMissingSegment(t *testing.T) {
+	for _, c := range []struct {
+		missing map[int]int
+		want    []string
+	}{
+		{map[int]int{12: http.StatusGone}, []string{"segment 10", "segment 11", "segment 13", "segment 14"}},
+		// segment 10 has left the reloaded playlist
+		{map[int]int{10: http.StatusNotFound}, []string{"segment 11", "segment 12", "segment 13", "segment 14"}},
+	} {
+		ts := httptest.NewServer(&liveServer{sequence: 10, end: 15, missing: c.missing})
+		s := &Stream{
+			Client: http.DefaultClient,
+			URL:    func() (string, error) { return ts.URL + "/playlist.m3u8", nil },
+		}
+		got := runStream(t, s)
+		ts.Close()
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("missing %v: got %v, want %v", c.missing, got, c.want)
+		}
+	}
+}
+
+func TestStreamSegmentNotFound(t *testing.T) {
+	ts := httptest.NewServer(&liveServer{sequence: 10, end: 15, missing: map[int]int{12: http.StatusNotFound}})
+	defer ts.Close()
+
+	s := &Stream{
+		Client: http.DefaultClient,
+		URL:    func() (string, error) { return ts.URL + "/playlist.m3u8", nil },
+	}
+	err := s.Run(nil, func(seg Segment, data []byte) error { return nil })
+	if se, ok := err.(*StatusError); !ok || se.StatusCode != http.StatusNotFound {
+		t.Fatalf("got %v, want *StatusError with 404", err)
+	}
+	if s.Sequence != 11 {
+		t.Fatalf("got %d, want %d", s.Sequence, 11)
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	for _, c := range []struct {
+		sequence int
+		want     []string
+	}{
+		{11, []string{"segment 12", "segment 13", "segment 14"}},
+		// restarted stream
+		{100, []string{"segment 10", "segment 11", "segment 12", "segment 13", "segment 14"}},
+	} {
+		ts := httptest.NewServer(&liveServer{sequence: 10, end: 15})
+		s := &Stream{
+			Client: http.DefaultClient,
+			URL:    func() (string, error) { return ts.URL + "/playlist.m3u8", nil },
+		}
+		s.Resume(c.sequence)
+		got := runStream(t, s)
+		ts.Close()
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("resume %d: got %v, want %v", c.sequence, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// Package metrics collects counters, gauges and histograms and exposes them
+// in the Prometheus text format.
+//
+// Format spec: https://prometheus.io/docs/instrumenting/exposition_formats/
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds of histogram buckets for latencies in
+// seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Sample is a value of a metric with the values of its labels.
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// Registry is a set of metrics, it is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// metric is a family of series with the same name.
+type metric struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+	// collect returns the samples of a gauge function.
+	collect func() []Sample
+	// buckets are the upper bounds of a histogram.
+	buckets []float64
+}
+
+// series is a metric with the values of labels.
+type series struct {
+	labels []string
+	value  float64
+	counts []uint64
+	count  uint64
+}
+
+func (r *Registry) register(m *metric) *metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.metrics {
+		if e.name == m.name {
+			panic("metrics: duplicate metric " + m.name)
+		}
+	}
+	r.metrics = append(r.metrics, m)
+	return m
+}
+
+// get returns the series of the label values, creating it if not exists.
+// The caller must hold m.mu.
+func (m *metric) get(values []string) *series {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", m.name, len(m.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, found := m.series[key]
+	if !found {
+		s = &series{labels: append([]string(nil), values...)}
+		if m.buckets != nil {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		if m.series == nil {
+			m.series = make(map[string]*series)
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// Counter registers a counter with the names of labels.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(&metric{name: name, help: help, typ: "counter", labels: labels})}
+}
+
+// Counter is a cumulative metric that only increases.
+type Counter struct {
+	m *metric
+}
+
+// Add adds v to the series of the label values, v must not be negative.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.m.name + " cannot decrease")
+	}
+	c.m.mu.Lock()
+	c.m.get(values).value += v
+	c.m.mu.Unlock()
+}
+
+// Inc increments the series of the label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Gauge registers a gauge with the names of labels.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(&metric{name: name, help: help, typ: "gauge", labels: labels})}
+}
+
+// Gauge is a metric that can go up and down.
+type Gauge struct {
+	m *metric
+}
+
+// Set sets the series of the label values to v.
+func (g *Gauge) Set(v float64, values ...string) {
+	g.m.mu.Lock()
+	g.m.get(values).value = v
+	g.m.mu.Unlock()
+}
+
+// Add adds v to the series of the label values.
+func (g *Gauge) Add(v float64, values ...string) {
+	g.m.mu.Lock()
+	g.m.get(values).value += v
+	g.m.mu.Unlock()
+}
+
+// Delete removes the series of the label values.
+func (g *Gauge) Delete(values ...string) {
+	g.m.mu.Lock()
+	delete(g.m.series, strings.Join(values, "\xff"))
+	g.m.mu.Unlock()
+}
+
+// GaugeFunc registers a gauge whose samples are returned by collect on each
+// exposition.
+func (r *Registry) GaugeFunc(name, help string, labels []string, collect func() []Sample) {
+	r.register(&metric{name: name, help: help, typ: "gauge", labels: labels, collect: collect})
+}
+
+// Histogram registers a histogram with the upper bounds of buckets in
+// increasing order and the names of labels.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: buckets of " + name + " are not sorted")
+	}
+	m := &metric{name: name, help: help, typ: "histogram", labels: labels, buckets: buckets}
+	if m.buckets == nil {
+		m.buckets = []float64{}
+	}
+	return &Histogram{r.register(m)}
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	m *metric
+}
+
+// Observe adds v to the series of the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	s := h.m.get(values)
+	for i, upper := range h.m.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// Write writes the metrics in the text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]*metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics in the text format.
+func (r *Registry) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", ContentType)
+	r.Write(rw)
+}
+
+func (m *metric) write(w *bufio.Writer) {
+	var list []*series
+	if m.collect != nil {
+		for _, sample := range m.collect() {
+			list = append(list, &series{labels: sample.Labels, value: sample.Value})
+		}
+	} else {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, s := range m.series {
+			list = append(list, s)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return lessLabels(list[i].labels, list[j].labels)
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.typ)
+	for _, s := range list {
+		if m.typ != "histogram" {
+			writeSample(w, m.name, m.labels, s.labels, "", "", s.value)
+			continue
+		}
+		for i, upper := range m.buckets {
+			writeSample(w, m.name+"_bucket", m.labels, s.labels, "le", formatFloat(upper), float64(s.counts[i]))
+		}
+		writeSample(w, m.name+"_bucket", m.labels, s.labels, "le", "+Inf", float64(s.count))
+		writeSample(w, m.name+"_sum", m.labels, s.labels, "", "", s.value)
+		writeSample(w, m.name+"_count", m.labels, s.labels, "", "", float64(s.count))
+	}
+}
+
+// writeSample writes a sample line, with the extra label if name is not
+// empty.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			var value string
+			if i < len(values) {
+				value = values[i]
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabel(value))
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func lessLabels(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
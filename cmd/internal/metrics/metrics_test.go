@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := new(Registry)
+	requests := r.Counter("requests_total", "Number of requests.", "endpoint", "code")
+	requests.Inc("play.do", "200")
+	requests.Add(2, "getRanking.do", "200")
+	requests.Inc("play.do", "200")
+	listeners := r.Gauge("listeners", "Active listeners.")
+	listeners.Set(3)
+	listeners.Add(-1)
+	latency := r.Histogram("latency_seconds", "Latency\nof requests.", []float64{0.1, 1}, "endpoint")
+	latency.Observe(0.05, "play.do")
+	latency.Observe(0.5, "play.do")
+	latency.Observe(2, "play.do")
+	r.GaugeFunc("rank", "Rank of channels.", []string{"title"}, func() []Sample {
+		return []Sample{{[]string{`a "b"`}, 1}}
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{endpoint="getRanking.do",code="200"} 2
+requests_total{endpoint="play.do",code="200"} 2
+# HELP listeners Active listeners.
+# TYPE listeners gauge
+listeners 2
+# HELP latency_seconds Latency\nof requests.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{endpoint="play.do",le="0.1"} 1
+latency_seconds_bucket{endpoint="play.do",le="1"} 2
+latency_seconds_bucket{endpoint="play.do",le="+Inf"} 3
+latency_seconds_sum{endpoint="play.do"} 2.55
+latency_seconds_count{endpoint="play.do"} 3
+# HELP rank Rank of channels.
+# TYPE rank gauge
+rank{title="a \"b\""} 1
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGaugeDelete(t *testing.T) {
+	r := new(Registry)
+	g := r.Gauge("g", "A gauge.", "id")
+	g.Set(1, "1")
+	g.Set(2, "2")
+	g.Delete("1")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != ContentType {
+		t.Fatalf("got %q, want %q", got, ContentType)
+	}
+	want := "# HELP g A gauge.\n# TYPE g gauge\ng{id=\"2\"} 2\n"
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	r := new(Registry)
+	r.Counter("c", "A counter.")
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	r.Gauge("c", "A gauge.")
+}
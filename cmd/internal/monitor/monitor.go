@@ -0,0 +1,182 @@
+// Package monitor detects dead air and outages of live streams: prolonged
+// silence, stalled media sequence numbers and repeated fetch failures.
+package monitor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Condition is a kind of problem of a stream.
+type Condition string
+
+// Conditions of alerts.
+const (
+	Silence Condition = "silence"
+	Stalled Condition = "stalled"
+	Failure Condition = "failure"
+)
+
+// Alert represents a detected condition or a recovery of it.
+type Alert struct {
+	Time      time.Time `json:"time"`
+	ChannelID int       `json:"channel_id"`
+	Title     string    `json:"channel_title"`
+	Condition Condition `json:"condition"`
+	Recovered bool      `json:"recovered"`
+	// Since is when the condition started.
+	Since   time.Time `json:"since"`
+	Message string    `json:"message"`
+}
+
+// Thresholds are the limits of conditions, zero values disable the detection.
+type Thresholds struct {
+	// Silence is the duration of audio quieter than SilenceLevel in dBFS.
+	Silence      time.Duration
+	SilenceLevel float64
+	// Stall is the duration without new segments.
+	Stall time.Duration
+	// Failures is the number of consecutive fetch failures.
+	Failures int
+}
+
+// Channel tracks the conditions of the stream of a channel, it is safe for
+// concurrent use.
+type Channel struct {
+	ID         int
+	Title      string
+	Thresholds Thresholds
+
+	mu           sync.Mutex
+	started      bool
+	lastProgress time.Time
+	silentSince  time.Time
+	silent       time.Duration
+	failures     int
+	failingSince time.Time
+	active       map[Condition]time.Time
+}
+
+// Start starts tracking at now, a stall is detected if no segments are
+// received since now.
+func (c *Channel) Start(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started = true
+	c.lastProgress = now
+}
+
+// Segment records a new segment of the duration received at now. The level
+// of the audio is in dBFS, it is NaN if unknown.
+func (c *Channel) Segment(now time.Time, duration time.Duration, level float64) []Alert {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started = true
+	c.lastProgress = now
+	c.failures = 0
+
+	var alerts []Alert
+	alerts = c.recover(alerts, now, Stalled)
+	alerts = c.recover(alerts, now, Failure)
+
+	if math.IsNaN(level) || c.Thresholds.Silence <= 0 {
+		return alerts
+	}
+	if level >= c.Thresholds.SilenceLevel {
+		c.silent = 0
+		return c.recover(alerts, now, Silence)
+	}
+	if c.silent == 0 {
+		c.silentSince = now.Add(-duration)
+	}
+	c.silent += duration
+	if c.silent >= c.Thresholds.Silence {
+		alerts = c.alert(alerts, now, Silence, c.silentSince,
+			fmt.Sprintf("silent for %s below %.0f dBFS", c.silent, c.Thresholds.SilenceLevel))
+	}
+	return alerts
+}
+
+// Fail records a fetch failure at now.
+func (c *Channel) Fail(now time.Time, err error) []Alert {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures == 0 {
+		c.failingSince = now
+	}
+	c.failures++
+	if c.Thresholds.Failures <= 0 || c.failures < c.Thresholds.Failures {
+		return nil
+	}
+	return c.alert(nil, now, Failure, c.failingSince,
+		fmt.Sprintf("%d consecutive failures: %s", c.failures, err))
+}
+
+// Check detects a stall at now.
+func (c *Channel) Check(now time.Time) []Alert {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.started || c.Thresholds.Stall <= 0 {
+		return nil
+	}
+	if d := now.Sub(c.lastProgress); d >= c.Thresholds.Stall {
+		return c.alert(nil, now, Stalled, c.lastProgress,
+			fmt.Sprintf("no new segments for %s", d/time.Second*time.Second))
+	}
+	return nil
+}
+
+// alert appends the alert of the condition unless it is active.
+func (c *Channel) alert(alerts []Alert, now time.Time, cond Condition, since time.Time, message string) []Alert {
+	if _, active := c.active[cond]; active {
+		return alerts
+	}
+	if c.active == nil {
+		c.active = make(map[Condition]time.Time)
+	}
+	c.active[cond] = since
+	return append(alerts, Alert{
+		Time:      now,
+		ChannelID: c.ID,
+		Title:     c.Title,
+		Condition: cond,
+		Since:     since,
+		Message:   message,
+	})
+}
+
+// recover appends the recovery of the condition if it is active.
+func (c *Channel) recover(alerts []Alert, now time.Time, cond Condition) []Alert {
+	since, active := c.active[cond]
+	if !active {
+		return alerts
+	}
+	delete(c.active, cond)
+	return append(alerts, Alert{
+		Time:      now,
+		ChannelID: c.ID,
+		Title:     c.Title,
+		Condition: cond,
+		Recovered: true,
+		Since:     since,
+		Message:   fmt.Sprintf("recovered after %s", now.Sub(since)/time.Second*time.Second),
+	})
+}
+
+// Level returns the RMS level in dBFS of signed 16-bit little-endian PCM
+// samples, it is -Inf for digital silence and NaN if there are no samples.
+func Level(pcm []byte) float64 {
+	n := len(pcm) / 2
+	if n == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for i := 0; i < n; i++ {
+		s := float64(int16(binary.LittleEndian.Uint16(pcm[2*i:]))) / 32768
+		sum += s * s
+	}
+	return 10 * math.Log10(sum/float64(n))
+}
@@ -0,0 +1,97 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2015, 3, 1, 17, 0, 0, 0, time.UTC)
+
+func conditions(alerts []Alert) []string {
+	var got []string
+	for _, a := range alerts {
+		s := string(a.Condition)
+		if a.Recovered {
+			s += " recovered"
+		}
+		got = append(got, s)
+	}
+	return got
+}
+
+func expect(t *testing.T, step string, alerts []Alert, want ...string) {
+	got := conditions(alerts)
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %v, want %v", step, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s: got %v, want %v", step, got, want)
+		}
+	}
+}
+
+func TestChannelSilence(t *testing.T) {
+	c := &Channel{ID: 222, Thresholds: Thresholds{Silence: 30 * time.Second, SilenceLevel: -50}}
+	c.Start(t0)
+	seg := 10 * time.Second
+
+	expect(t, "loud", c.Segment(t0.Add(10*time.Second), seg, -20))
+	expect(t, "silent 10s", c.Segment(t0.Add(20*time.Second), seg, -60))
+	expect(t, "silent 20s", c.Segment(t0.Add(30*time.Second), seg, math.Inf(-1)))
+	alerts := c.Segment(t0.Add(40*time.Second), seg, -70)
+	expect(t, "silent 30s", alerts, "silence")
+	if !alerts[0].Since.Equal(t0.Add(10*time.Second)) || alerts[0].ChannelID != 222 {
+		t.Fatalf("got %+v", alerts[0])
+	}
+	expect(t, "still silent", c.Segment(t0.Add(50*time.Second), seg, -70))
+	expect(t, "unknown level", c.Segment(t0.Add(60*time.Second), seg, math.NaN()))
+	alerts = c.Segment(t0.Add(70*time.Second), seg, -30)
+	expect(t, "loud again", alerts, "silence recovered")
+	if alerts[0].Message != "recovered after 1m0s" {
+		t.Fatalf("got message %q", alerts[0].Message)
+	}
+}
+
+func TestChannelStalledAndFailures(t *testing.T) {
+	c := &Channel{ID: 222, Thresholds: Thresholds{Stall: time.Minute, Failures: 3}}
+	expect(t, "not started", c.Check(t0.Add(time.Hour)))
+	c.Start(t0)
+
+	expect(t, "progress", c.Segment(t0.Add(10*time.Second), 10*time.Second, math.NaN()))
+	expect(t, "within stall", c.Check(t0.Add(69*time.Second)))
+	expect(t, "stalled", c.Check(t0.Add(70*time.Second)), "stalled")
+	expect(t, "still stalled", c.Check(t0.Add(80*time.Second)))
+
+	err := errors.New("403 Forbidden")
+	expect(t, "failure 1", c.Fail(t0.Add(81*time.Second), err))
+	expect(t, "failure 2", c.Fail(t0.Add(82*time.Second), err))
+	expect(t, "failure 3", c.Fail(t0.Add(83*time.Second), err), "failure")
+	expect(t, "failure 4", c.Fail(t0.Add(84*time.Second), err))
+
+	expect(t, "recovered", c.Segment(t0.Add(90*time.Second), 10*time.Second, math.NaN()), "stalled recovered", "failure recovered")
+	expect(t, "failure again", c.Fail(t0.Add(91*time.Second), err))
+}
+
+func TestLevel(t *testing.T) {
+	pcm := func(samples ...int16) []byte {
+		b := make([]byte, 2*len(samples))
+		for i, s := range samples {
+			binary.LittleEndian.PutUint16(b[2*i:], uint16(s))
+		}
+		return b
+	}
+	if got := Level(pcm(0, 0, 0)); !math.IsInf(got, -1) {
+		t.Fatalf("got %v, want -Inf", got)
+	}
+	if got := Level(nil); !math.IsNaN(got) {
+		t.Fatalf("got %v, want NaN", got)
+	}
+	// a square wave of half scale
+	if got := Level(pcm(16384, -16384, 16384, -16384)); math.Abs(got-(-6.02)) > 0.01 {
+		t.Fatalf("got %v, want -6.02", got)
+	}
+}
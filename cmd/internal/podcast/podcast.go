@@ -0,0 +1,119 @@
+// Package podcast generates podcast feeds of recordings.
+//
+// Spec: http://www.rssboard.org/rss-specification
+// and https://help.apple.com/itc/podcasts_connect/#/itcb54353390
+package podcast
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/recording"
+)
+
+const itunesNS = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+// Feed describes a podcast.
+type Feed struct {
+	Title       string
+	Link        string
+	Description string
+	Image       string
+	Author      string
+}
+
+// URLFunc returns the absolute URL of the recorded file.
+type URLFunc func(r recording.Recording) string
+
+type rss struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Itunes  string   `xml:"xmlns:itunes,attr"`
+	Channel channel  `xml:"channel"`
+}
+
+type channel struct {
+	Title       string       `xml:"title"`
+	Link        string       `xml:"link"`
+	Description string       `xml:"description"`
+	Language    string       `xml:"language"`
+	Generator   string       `xml:"generator"`
+	Image       *image       `xml:"image,omitempty"`
+	ItunesImage *itunesImage `xml:"itunes:image,omitempty"`
+	Author      string       `xml:"itunes:author,omitempty"`
+	Explicit    string       `xml:"itunes:explicit"`
+	Items       []item       `xml:"item"`
+}
+
+type image struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type item struct {
+	Title       string    `xml:"title"`
+	Description string    `xml:"description"`
+	Enclosure   enclosure `xml:"enclosure"`
+	GUID        guid      `xml:"guid"`
+	PubDate     string    `xml:"pubDate"`
+	Duration    string    `xml:"itunes:duration"`
+}
+
+type enclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type guid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// Write writes the RSS 2.0 feed of recordings with iTunes tags to w.
+// Recordings are listed in the given order, the content type of enclosures
+// is given by contentType.
+func Write(w io.Writer, f Feed, recordings []recording.Recording, url URLFunc, contentType func(name string) string) error {
+	ch := channel{
+		Title:       f.Title,
+		Link:        f.Link,
+		Description: f.Description,
+		Language:    "zh-tw",
+		Generator:   "hiradio",
+		Author:      f.Author,
+		Explicit:    "false",
+	}
+	if f.Image != "" {
+		ch.Image = &image{URL: f.Image, Title: f.Title, Link: f.Link}
+		ch.ItunesImage = &itunesImage{Href: f.Image}
+	}
+	for _, r := range recordings {
+		ch.Items = append(ch.Items, item{
+			Title:       fmt.Sprintf("%s %s", r.Program, r.Start.Format("2006-01-02")),
+			Description: fmt.Sprintf("%s %s ~ %s %s", r.ChannelTitle, r.Start.Format("2006-01-02 15:04"), r.Stop.Format("15:04"), r.ChannelDesc),
+			Enclosure:   enclosure{URL: url(r), Length: r.Size, Type: contentType(r.File)},
+			GUID:        guid{Value: fmt.Sprintf("hiradio:%d:%d", r.ChannelID, r.Start.Unix())},
+			PubDate:     r.Start.Format(time.RFC1123Z),
+			Duration:    formatDuration(r.Duration()),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(rss{Version: "2.0", Itunes: itunesNS, Channel: ch})
+}
+
+func formatDuration(d time.Duration) string {
+	d = d / time.Second
+	return fmt.Sprintf("%02d:%02d:%02d", d/3600, d/60%60, d%60)
+}
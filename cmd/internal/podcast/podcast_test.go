@@ -0,0 +1,67 @@
+package podcast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/recording"
+)
+
+func TestWrite(t *testing.T) {
+	start := time.Date(2015, 3, 1, 17, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	recordings := []recording.Recording{
+		{
+			ChannelID:    222,
+			ChannelTitle: "HitFm聯播網 Taipei 北部",
+			ChannelDesc:  "Hit FM",
+			Program:      "週日 HIT DJ",
+			Start:        start,
+			Stop:         start.Add(90 * time.Minute),
+			File:         "222/20150301-1700-週日_HIT_DJ.ts",
+			Size:         1024,
+		},
+	}
+	feed := Feed{
+		Title:       "HitFm聯播網 Taipei 北部",
+		Link:        "http://hichannel.hinet.net/radio/index.do",
+		Description: "Hit FM",
+		Image:       "http://hichannel.hinet.net/upload/radio/channel/14abcde694d00000b2fc.jpg",
+	}
+	url := func(r recording.Recording) string { return "http://192.168.1.2:1077/podcast/files/" + r.File }
+	contentType := func(name string) string { return "video/mp2t" }
+
+	var buf bytes.Buffer
+	if err := Write(&buf, feed, recordings, url, contentType); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">`,
+		`<itunes:image href="http://hichannel.hinet.net/upload/radio/channel/14abcde694d00000b2fc.jpg"></itunes:image>`,
+		`<title>週日 HIT DJ 2015-03-01</title>`,
+		`<enclosure url="http://192.168.1.2:1077/podcast/files/222/20150301-1700-週日_HIT_DJ.ts" length="1024" type="video/mp2t"></enclosure>`,
+		`<guid isPermaLink="false">hiradio:222:1425200400</guid>`,
+		`<pubDate>Sun, 01 Mar 2015 17:00:00 +0800</pubDate>`,
+		`<itunes:duration>01:30:00</itunes:duration>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in feed:\n%s", want, out)
+		}
+	}
+
+	// well-formed
+	dec := xml.NewDecoder(&buf)
+	for {
+		if _, err := dec.Token(); err != nil {
+			if err != io.EOF {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package probe
+
+import "strings"
+
+// MPEG-TS spec: ISO/IEC 13818-1
+
+const tsPacketSize = 188
+
+// streamTypes are the codecs of elementary stream types in MPEG-TS.
+var streamTypes = map[byte]string{
+	0x03: "mp3",
+	0x04: "mp3",
+	0x0F: "aac",
+	0x11: "aac-latm",
+	0x1B: "h264",
+	0x24: "hevc",
+	0x81: "ac3",
+}
+
+// DetectCodecs returns the codecs of media data, separated by commas. An
+// empty string is returned if the format is unknown.
+func DetectCodecs(data []byte) string {
+	// skip ID3v2 tag
+	if len(data) >= 10 && string(data[:3]) == "ID3" {
+		size := 10 + (int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F))
+		if size > len(data) {
+			return ""
+		}
+		data = data[size:]
+	}
+
+	switch {
+	case len(data) >= tsPacketSize && data[0] == 0x47:
+		return strings.Join(tsCodecs(data), ",")
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xF6 == 0xF0:
+		// ADTS with layer 0
+		return "aac"
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return "mp3"
+	}
+	return ""
+}
+
+// tsCodecs returns the codecs of streams in the program map table of the
+// MPEG-TS data.
+func tsCodecs(data []byte) []string {
+	pmtPID := -1
+	for ; len(data) >= tsPacketSize; data = data[tsPacketSize:] {
+		packet := data[:tsPacketSize]
+		if packet[0] != 0x47 || packet[1]&0x40 == 0 {
+			// not the start of a section
+			continue
+		}
+		pid := int(packet[1]&0x1F)<<8 | int(packet[2])
+		payload := packet[4:]
+		if packet[3]&0x20 != 0 {
+			// adaptation field
+			if int(payload[0])+1 >= len(payload) {
+				continue
+			}
+			payload = payload[1+int(payload[0]):]
+		}
+		section := psiSection(payload)
+		if section == nil {
+			continue
+		}
+
+		switch {
+		case pid == 0 && pmtPID == -1:
+			// program association table
+			if len(section) < 5 {
+				return nil
+			}
+			for entries := section[5:]; len(entries) >= 4; entries = entries[4:] {
+				if program := int(entries[0])<<8 | int(entries[1]); program != 0 {
+					pmtPID = int(entries[2]&0x1F)<<8 | int(entries[3])
+					break
+				}
+			}
+		case pid == pmtPID:
+			// program map table
+			if len(section) < 9 {
+				return nil
+			}
+			infoLength := int(section[7]&0x0F)<<8 | int(section[8])
+			if 9+infoLength > len(section) {
+				return nil
+			}
+			var codecs []string
+			seen := make(map[string]bool)
+			for streams := section[9+infoLength:]; len(streams) >= 5; {
+				if codec, found := streamTypes[streams[0]]; found && !seen[codec] {
+					seen[codec] = true
+					codecs = append(codecs, codec)
+				}
+				esLength := int(streams[3]&0x0F)<<8 | int(streams[4])
+				if 5+esLength > len(streams) {
+					break
+				}
+				streams = streams[5+esLength:]
+			}
+			return codecs
+		}
+	}
+	return nil
+}
+
+// psiSection returns the section of a PSI payload after the table ID and
+// section length, excluding CRC.
+func psiSection(payload []byte) []byte {
+	if len(payload) < 1 || 1+int(payload[0])+3 > len(payload) {
+		return nil
+	}
+	table := payload[1+int(payload[0]):]
+	length := int(table[1]&0x0F)<<8 | int(table[2])
+	if length < 4 || 3+length > len(table) {
+		return nil
+	}
+	return table[3 : 3+length-4]
+}
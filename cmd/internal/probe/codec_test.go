@@ -0,0 +1,46 @@
+package probe
+
+import "testing"
+
+// tsPacket returns an MPEG-TS packet of the PSI section.
+func tsPacket(pid int, section []byte) []byte {
+	p := make([]byte, tsPacketSize)
+	for i := range p {
+		p[i] = 0xFF
+	}
+	p[0], p[1], p[2], p[3] = 0x47, 0x40|byte(pid>>8), byte(pid), 0x10
+	p[4] = 0 // pointer field
+	copy(p[5:], section)
+	return p
+}
+
+// testTS returns MPEG-TS data with an AAC stream and an ID3 metadata stream.
+func testTS() []byte {
+	crc := []byte{0, 0, 0, 0}
+	pat := append([]byte{0x00, 0xB0, 13, 0x00, 0x01, 0xC1, 0x00, 0x00, 0x00, 0x01, 0xF0, 0x00}, crc...)
+	pmt := append([]byte{0x02, 0xB0, 23, 0x00, 0x01, 0xC1, 0x00, 0x00, 0xE1, 0x00, 0xF0, 0x00,
+		0x0F, 0xE1, 0x00, 0xF0, 0x00,
+		0x15, 0xE1, 0x01, 0xF0, 0x00,
+	}, crc...)
+	return append(tsPacket(0, pat), tsPacket(0x1000, pmt)...)
+}
+
+func TestDetectCodecs(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"ts", testTS(), "aac"},
+		{"id3+ts", append([]byte("ID3\x04\x00\x00\x00\x00\x00\x02\x00\x00"), testTS()...), "aac"},
+		{"adts", []byte{0xFF, 0xF1, 0x50, 0x80}, "aac"},
+		{"mp3", []byte{0xFF, 0xFB, 0x90, 0x64}, "mp3"},
+		{"unknown", []byte("#EXTM3U"), ""},
+		{"truncated id3", []byte("ID3\x04\x00\x00\x00\x00\x01\x00"), ""},
+		{"short pat", tsPacket(0, []byte{0x00, 0xB0, 5, 0x00, 0x01, 0, 0, 0, 0}), ""},
+	} {
+		if got := DetectCodecs(c.data); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package probe checks the health of HLS live streams.
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+)
+
+// Stages of probing, a failed stage is reported in Result.
+const (
+	StagePlaylist = "playlist"
+	StageVariant  = "variant"
+	StageSegment  = "segment"
+)
+
+var errNoSegments = errors.New("no segments in playlist")
+
+// Result represents the health of a stream.
+type Result struct {
+	// Stage is the failed stage, it is empty if the stream is healthy.
+	Stage string `json:"stage,omitempty"`
+	// Status is the HTTP status of the last response.
+	Status int    `json:"status,omitempty"`
+	Codecs string `json:"codecs,omitempty"`
+	// DeclaredBitrate is the bandwidth of the variant in the master
+	// playlist, MeasuredBitrate is the bitrate of the fetched segments, in
+	// bits per second.
+	DeclaredBitrate int `json:"declared_bitrate,omitempty"`
+	MeasuredBitrate int `json:"measured_bitrate,omitempty"`
+	// TTFB is the time to first byte of the playlist, SegmentTTFB is the
+	// average one of segments.
+	TTFB        time.Duration `json:"ttfb"`
+	SegmentTTFB time.Duration `json:"segment_ttfb,omitempty"`
+	Segments    int           `json:"segments"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// OK reports whether the stream is healthy.
+func (r *Result) OK() bool {
+	return r.Error == ""
+}
+
+func (r *Result) fail(stage string, err error) *Result {
+	r.Stage = stage
+	r.Error = err.Error()
+	return r
+}
+
+// Probe fetches the playlist at url and the last n segments of the stream.
+// A master playlist is resolved to the variant of highest bandwidth.
+func Probe(client *http.Client, url string, n int) *Result {
+	r := new(Result)
+	data, status, ttfb, err := get(client, url)
+	r.Status, r.TTFB = status, ttfb
+	if err != nil {
+		return r.fail(StagePlaylist, err)
+	}
+	p, err := hls.Parse(bytes.NewReader(data))
+	if err != nil {
+		return r.fail(StagePlaylist, err)
+	}
+
+	if p.Master() {
+		best := p.Variants[0]
+		for _, v := range p.Variants[1:] {
+			if v.Bandwidth > best.Bandwidth {
+				best = v
+			}
+		}
+		r.DeclaredBitrate = best.Bandwidth
+		r.Codecs = best.Codecs
+		if url, err = hls.ResolveURL(url, best.URI); err != nil {
+			return r.fail(StageVariant, err)
+		}
+		data, r.Status, _, err = get(client, url)
+		if err != nil {
+			return r.fail(StageVariant, err)
+		}
+		if p, err = hls.Parse(bytes.NewReader(data)); err != nil {
+			return r.fail(StageVariant, err)
+		}
+	}
+	if len(p.Segments) == 0 {
+		return r.fail(StagePlaylist, errNoSegments)
+	}
+
+	// the oldest segments may be removed soon
+	segments := p.Segments
+	if len(segments) > n {
+		segments = segments[len(segments)-n:]
+	}
+	var size int
+	var duration, totalTTFB time.Duration
+	for _, seg := range segments {
+		u, err := hls.ResolveURL(url, seg.URI)
+		if err != nil {
+			return r.fail(StageSegment, err)
+		}
+		data, status, ttfb, err := get(client, u)
+		r.Status = status
+		if err != nil {
+			return r.fail(StageSegment, err)
+		}
+		if r.Codecs == "" {
+			r.Codecs = DetectCodecs(data)
+		}
+		r.Segments++
+		size += len(data)
+		duration += seg.Duration
+		totalTTFB += ttfb
+	}
+	r.SegmentTTFB = totalTTFB / time.Duration(r.Segments)
+	if duration > 0 {
+		r.MeasuredBitrate = int(float64(size*8) / duration.Seconds())
+	}
+	return r
+}
+
+// get fetches the resource at url, and returns the status and the time to
+// first byte of the response.
+func get(client *http.Client, url string) ([]byte, int, time.Duration, error) {
+	start := time.Now()
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer resp.Body.Close()
+	ttfb := time.Since(start)
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, ttfb, &hls.StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	return data, resp.StatusCode, ttfb, err
+}
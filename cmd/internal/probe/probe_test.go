@@ -0,0 +1,49 @@
+package probe
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	segment := append(testTS(), bytes.Repeat([]byte{0x47}, 4624)...) // 5000 bytes
+	mux := http.NewServeMux()
+	mux.HandleFunc("/playlist.m3u8", func(rw http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(rw, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=64000\nlive/chunklist.m3u8\n")
+	})
+	mux.HandleFunc("/live/chunklist.m3u8", func(rw http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(rw, "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXT-X-MEDIA-SEQUENCE:1\n")
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(rw, "#EXTINF:10.0,\nmedia_%d.ts\n", i)
+		}
+	})
+	mux.HandleFunc("/live/media_2.ts", func(rw http.ResponseWriter, req *http.Request) { rw.Write(segment) })
+	mux.HandleFunc("/live/media_3.ts", func(rw http.ResponseWriter, req *http.Request) { rw.Write(segment) })
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	r := Probe(http.DefaultClient, ts.URL+"/playlist.m3u8", 2)
+	if !r.OK() {
+		t.Fatalf("unexpected err: %s", r.Error)
+	}
+	if r.Status != http.StatusOK || r.Codecs != "aac" || r.Segments != 2 {
+		t.Fatalf("got %+v", r)
+	}
+	if r.DeclaredBitrate != 64000 || r.MeasuredBitrate != 4000 {
+		t.Fatalf("got bitrate %d/%d, want %d/%d", r.DeclaredBitrate, r.MeasuredBitrate, 64000, 4000)
+	}
+
+	// the oldest segment is gone
+	r = Probe(http.DefaultClient, ts.URL+"/playlist.m3u8", 3)
+	if r.OK() || r.Stage != StageSegment || r.Status != http.StatusNotFound {
+		t.Fatalf("got %+v, want a failed segment", r)
+	}
+
+	r = Probe(http.DefaultClient, ts.URL+"/none.m3u8", 2)
+	if r.OK() || r.Stage != StagePlaylist || r.Status != http.StatusNotFound {
+		t.Fatalf("got %+v, want a failed playlist", r)
+	}
+}
@@ -0,0 +1,190 @@
+// Package rankings stores snapshots of Hichannel rankings.
+package rankings
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/parkghost/hiradio"
+)
+
+// Snapshot represents the rankings at a point in time.
+type Snapshot struct {
+	Time     time.Time         `json:"time"`
+	Rankings []hiradio.Ranking `json:"rankings"`
+}
+
+// Rank returns the rank of channel, zero if it is not ranked.
+func (s Snapshot) Rank(channelID int) int {
+	for _, r := range s.Rankings {
+		if r.ID == channelID {
+			return r.Value
+		}
+	}
+	return 0
+}
+
+// Top returns the first n rankings of the snapshot in order of rank.
+func (s Snapshot) Top(n int) []hiradio.Ranking {
+	list := append([]hiradio.Ranking(nil), s.Rankings...)
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Value < list[j].Value
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// Append appends the snapshot to the file name.
+func Append(name string, s Snapshot) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load loads the snapshots taken in [from, to) from the file name, in order
+// of time. Zero from or to means unbounded. Malformed lines are skipped.
+func Load(name string, from, to time.Time) ([]Snapshot, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var snapshots []Snapshot
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var s Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			continue
+		}
+		if !from.IsZero() && s.Time.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !s.Time.Before(to) {
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Stable(byTime(snapshots))
+	return snapshots, nil
+}
+
+type byTime []Snapshot
+
+func (s byTime) Len() int           { return len(s) }
+func (s byTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byTime) Less(i, j int) bool { return s[i].Time.Before(s[j].Time) }
+
+// Move represents the rank change of a channel between two snapshots. Zero
+// rank means not ranked.
+type Move struct {
+	ChannelID int `json:"channel_id"`
+	From      int `json:"from"`
+	To        int `json:"to"`
+}
+
+// Delta returns the number of places moved up, negative if moved down.
+// Entering or leaving the rankings is counted from the bottom of rankings.
+func (m Move) Delta(bottom int) int {
+	from, to := m.From, m.To
+	if from == 0 {
+		from = bottom + 1
+	}
+	if to == 0 {
+		to = bottom + 1
+	}
+	return from - to
+}
+
+// Movers returns the channels whose rank changed from the first to the last
+// snapshot, in descending order of the size of the move.
+func Movers(first, last Snapshot) []Move {
+	ids := make(map[int]bool)
+	bottom := 0
+	for _, s := range []Snapshot{first, last} {
+		for _, r := range s.Rankings {
+			ids[r.ID] = true
+			if r.Value > bottom {
+				bottom = r.Value
+			}
+		}
+	}
+
+	var moves []Move
+	for id := range ids {
+		m := Move{ChannelID: id, From: first.Rank(id), To: last.Rank(id)}
+		if m.From != m.To {
+			moves = append(moves, m)
+		}
+	}
+	sort.Slice(moves, func(i, j int) bool {
+		di, dj := abs(moves[i].Delta(bottom)), abs(moves[j].Delta(bottom))
+		if di != dj {
+			return di > dj
+		}
+		return moves[i].ChannelID < moves[j].ChannelID
+	})
+	return moves
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// Series returns the ranks of channel in each snapshot.
+func Series(snapshots []Snapshot, channelID int) []int {
+	series := make([]int, len(snapshots))
+	for i, s := range snapshots {
+		series[i] = s.Rank(channelID)
+	}
+	return series
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline renders ranks as a sparkline, higher bars for better ranks and
+// spaces for not ranked. The scale is from rank 1 to bottom.
+func Sparkline(ranks []int, bottom int) string {
+	line := make([]rune, len(ranks))
+	for i, r := range ranks {
+		if r == 0 || r > bottom {
+			line[i] = ' '
+			continue
+		}
+		level := 0
+		if bottom > 1 {
+			level = (bottom - r) * (len(sparks) - 1) / (bottom - 1)
+		} else {
+			level = len(sparks) - 1
+		}
+		line[i] = sparks[level]
+	}
+	return string(line)
+}
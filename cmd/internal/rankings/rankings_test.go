@@ -0,0 +1,96 @@
+package rankings
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parkghost/hiradio"
+)
+
+var base = time.Date(2015, 3, 1, 0, 0, 0, 0, time.UTC)
+
+var testSnapshots = []Snapshot{
+	{base, []hiradio.Ranking{{ID: 222, Value: 1}, {ID: 156, Value: 2}, {ID: 206, Value: 3}}},
+	{base.Add(24 * time.Hour), []hiradio.Ranking{{ID: 156, Value: 1}, {ID: 222, Value: 2}, {ID: 308, Value: 3}}},
+	{base.Add(48 * time.Hour), []hiradio.Ranking{{ID: 308, Value: 1}, {ID: 222, Value: 2}, {ID: 156, Value: 3}}},
+}
+
+func TestAppendAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hiradio")
+	if err != nil {
+		t.Fatalf("unexpected error on TempDir: %s", err)
+	}
+	file := filepath.Join(dir, "rankings.jsonl")
+
+	// append out of order
+	for _, i := range []int{1, 0, 2} {
+		if err := Append(file, testSnapshots[i]); err != nil {
+			t.Fatalf("unexpected error on Append: %s", err)
+		}
+	}
+
+	got, err := Load(file, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error on Load: %s", err)
+	}
+	if !reflect.DeepEqual(got, testSnapshots) {
+		t.Fatalf("got %+v, want %+v", got, testSnapshots)
+	}
+
+	got, err = Load(file, base.Add(time.Hour), base.Add(48*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error on Load: %s", err)
+	}
+	if want := testSnapshots[1:2]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestMovers(t *testing.T) {
+	got := Movers(testSnapshots[0], testSnapshots[2])
+	want := []Move{
+		{308, 0, 1},
+		{156, 2, 3},
+		{206, 3, 0},
+		{222, 1, 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if d := got[0].Delta(3); d != 3 {
+		t.Fatalf("got delta %d, want %d", d, 3)
+	}
+	if d := got[2].Delta(3); d != -1 {
+		t.Fatalf("got delta %d, want %d", d, -1)
+	}
+}
+
+func TestTop(t *testing.T) {
+	s := Snapshot{Rankings: []hiradio.Ranking{{ID: 156, Value: 3}, {ID: 222, Value: 1}, {ID: 308, Value: 4}, {ID: 206, Value: 2}}}
+	want := []hiradio.Ranking{{ID: 222, Value: 1}, {ID: 206, Value: 2}, {ID: 156, Value: 3}}
+	if got := s.Top(3); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got := s.Top(10); len(got) != 4 {
+		t.Fatalf("got %d rankings, want %d", len(got), 4)
+	}
+	if s.Rankings[0].ID != 156 {
+		t.Fatal("rankings of the snapshot should not be sorted")
+	}
+}
+
+func TestSeriesAndSparkline(t *testing.T) {
+	series := Series(testSnapshots, 308)
+	if want := []int{0, 3, 1}; !reflect.DeepEqual(series, want) {
+		t.Fatalf("got %v, want %v", series, want)
+	}
+
+	got := Sparkline([]int{1, 2, 3, 0, 5}, 3)
+	want := "█▄▁  "
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
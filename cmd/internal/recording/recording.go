@@ -0,0 +1,122 @@
+// Package recording stores metadata of audio recorded from Hichannel streams
+// in sidecar files.
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// metadataExt is the extension of sidecar files, appended to the name of the
+// recorded file.
+const metadataExt = ".json"
+
+// Recording represents a recorded program.
+type Recording struct {
+	ChannelID    int       `json:"channel_id"`
+	ChannelTitle string    `json:"channel_title"`
+	ChannelDesc  string    `json:"channel_desc,omitempty"`
+	ChannelImage string    `json:"channel_image,omitempty"`
+	Program      string    `json:"program"`
+	Start        time.Time `json:"start"`
+	Stop         time.Time `json:"stop"`
+
+	// File is the path of the recorded file relative to the recordings
+	// directory, with slash separators.
+	File string `json:"file"`
+	// Size is the size of the recorded file, it is not stored.
+	Size int64 `json:"-"`
+}
+
+// Duration returns the duration of the recording.
+func (r Recording) Duration() time.Duration {
+	return r.Stop.Sub(r.Start)
+}
+
+// FileName returns the path of the file recording the program started at
+// start, e.g. 222/20150301-1700-HIT_DJ.ts.
+func FileName(channelID int, program string, start time.Time, ext string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, program)
+	return fmt.Sprintf("%d/%s-%s%s", channelID, start.Format("20060102-1504"), name, ext)
+}
+
+// Path returns the path of the recorded file in dir.
+func (r Recording) Path(dir string) string {
+	return filepath.Join(dir, filepath.FromSlash(r.File))
+}
+
+// Save writes the metadata of the recording in dir.
+func Save(dir string, r Recording) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.Path(dir)+metadataExt, data, 0644)
+}
+
+// Load loads the recordings in dir, the most recent first. Recordings whose
+// file is missing are skipped, and so are JSON files which are not the
+// sidecar of a file in dir.
+func Load(dir string) ([]Recording, error) {
+	var list []Recording
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if fi.IsDir() || !strings.HasSuffix(path, metadataExt) {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var r Recording
+		if err := json.Unmarshal(data, &r); err != nil || !r.inDir() || path != r.Path(dir)+metadataExt {
+			// not a sidecar file
+			return nil
+		}
+		info, err := os.Stat(r.Path(dir))
+		if err != nil {
+			return nil
+		}
+		r.Size = info.Size()
+		list = append(list, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byStartDesc(list))
+	return list, nil
+}
+
+// inDir reports whether File is a relative path inside the recordings
+// directory.
+func (r Recording) inDir() bool {
+	if r.File == "" || filepath.IsAbs(filepath.FromSlash(r.File)) {
+		return false
+	}
+	name := filepath.Clean(filepath.FromSlash(r.File))
+	return name != "." && name != ".." && !strings.HasPrefix(name, ".."+string(filepath.Separator))
+}
+
+type byStartDesc []Recording
+
+func (s byStartDesc) Len() int           { return len(s) }
+func (s byStartDesc) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byStartDesc) Less(i, j int) bool { return s[i].Start.After(s[j].Start) }
@@ -0,0 +1,96 @@
+package recording
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2015, 3, 1, 17, 0, 0, 0, time.UTC)
+
+func TestFileName(t *testing.T) {
+	got := FileName(222, "週日 HIT DJ/Live", t0, ".ts")
+	if want := "222/20150301-1700-週日_HIT_DJ_Live.ts"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	recordings := []Recording{
+		{ChannelID: 222, Program: "週日 HIT DJ", Start: t0, Stop: t0.Add(1 * time.Hour)},
+		{ChannelID: 222, Program: "HITO唱片行", Start: t0.Add(1 * time.Hour), Stop: t0.Add(3 * time.Hour)},
+		{ChannelID: 88, Program: "Missing", Start: t0, Stop: t0.Add(1 * time.Hour)},
+	}
+	for i, r := range recordings {
+		r.File = FileName(r.ChannelID, r.Program, r.Start, ".ts")
+		if err := os.MkdirAll(filepath.Dir(r.Path(dir)), 0755); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if i < 2 {
+			if err := ioutil.WriteFile(r.Path(dir), make([]byte, 10*(i+1)), 0644); err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+		}
+		if err := Save(dir, r); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	list, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("got %d recordings, want %d", len(list), 2)
+	}
+	if list[0].Program != "HITO唱片行" || list[0].Size != 20 || list[0].Duration() != 2*time.Hour {
+		t.Fatalf("got %+v, want the most recent recording", list[0])
+	}
+
+	if list, err := Load(filepath.Join(dir, "none")); err != nil || len(list) != 0 {
+		t.Fatalf("got %v, %v, want no recordings", list, err)
+	}
+}
+
+func TestLoadForeignFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	defer os.RemoveAll(root)
+	dir := filepath.Join(root, "recordings")
+
+	files := map[string]string{
+		"outside.ts":                   "",
+		"recordings/222/a.ts":          "",
+		"recordings/settings.json":     `{"program":"not a recording"}`,
+		"recordings/escape.json":       `{"program":"escape","file":"../outside.ts"}`,
+		"recordings/absolute.json":     `{"program":"absolute","file":"` + filepath.ToSlash(filepath.Join(root, "outside.ts")) + `"}`,
+		"recordings/222/other.ts.json": `{"program":"misplaced","file":"222/a.ts"}`,
+	}
+	for name, data := range files {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	list, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("got %+v, want no recordings", list)
+	}
+}
@@ -0,0 +1,70 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Policy represents retention rules of recordings, zero values disable the
+// rules.
+type Policy struct {
+	// KeepLast is the number of the most recent recordings kept for each
+	// program of a channel.
+	KeepLast int
+	// MaxAge is the maximum age of recordings.
+	MaxAge time.Duration
+	// MaxSize is the maximum total size of recordings, the oldest ones are
+	// removed first.
+	MaxSize int64
+}
+
+// IsZero reports whether no rules are set.
+func (p Policy) IsZero() bool {
+	return p == Policy{}
+}
+
+// Expired returns the recordings of list, sorted the most recent first,
+// which are expired by the policy at now.
+func Expired(list []Recording, p Policy, now time.Time) []Recording {
+	type programKey struct {
+		channelID int
+		program   string
+	}
+	episodes := make(map[programKey]int)
+	var expired []Recording
+	var total int64
+	full := false
+	for _, r := range list {
+		key := programKey{r.ChannelID, r.Program}
+		episodes[key]++
+		switch {
+		case p.KeepLast > 0 && episodes[key] > p.KeepLast,
+			p.MaxAge > 0 && now.Sub(r.Start) > p.MaxAge:
+			expired = append(expired, r)
+		case full || p.MaxSize > 0 && total+r.Size > p.MaxSize:
+			full = true
+			expired = append(expired, r)
+		default:
+			total += r.Size
+		}
+	}
+	return expired
+}
+
+// Remove removes the recorded file and metadata of the recording in dir, the
+// directory of the channel is removed if it is empty.
+func Remove(dir string, r Recording) error {
+	name := r.Path(dir)
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(name + metadataExt); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// fails if not empty
+	if parent := filepath.Dir(name); parent != filepath.Clean(dir) {
+		os.Remove(parent)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package recording
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpired(t *testing.T) {
+	// the most recent first
+	list := []Recording{
+		{ChannelID: 222, Program: "HIT DJ", Start: t0.Add(-1 * time.Hour), Size: 30},
+		{ChannelID: 222, Program: "HITO唱片行", Start: t0.Add(-2 * time.Hour), Size: 30},
+		{ChannelID: 222, Program: "HIT DJ", Start: t0.Add(-25 * time.Hour), Size: 30},
+		{ChannelID: 88, Program: "HIT DJ", Start: t0.Add(-26 * time.Hour), Size: 10},
+		{ChannelID: 222, Program: "HIT DJ", Start: t0.Add(-49 * time.Hour), Size: 10},
+	}
+	for _, c := range []struct {
+		policy Policy
+		want   []int
+	}{
+		{Policy{}, nil},
+		{Policy{KeepLast: 1}, []int{2, 4}},
+		{Policy{MaxAge: 48 * time.Hour}, []int{4}},
+		{Policy{MaxSize: 70}, []int{2, 3, 4}},
+		{Policy{KeepLast: 2, MaxAge: 48 * time.Hour, MaxSize: 100}, []int{4}},
+		{Policy{KeepLast: 1, MaxSize: 70}, []int{2, 4}},
+	} {
+		got := Expired(list, c.policy, t0)
+		if len(got) != len(c.want) {
+			t.Errorf("%+v: got %d expired, want %v", c.policy, len(got), c.want)
+			continue
+		}
+		for i, j := range c.want {
+			if got[i] != list[j] {
+				t.Errorf("%+v: got %+v, want %+v", c.policy, got[i], list[j])
+			}
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := Recording{ChannelID: 222, Program: "HIT DJ", Start: t0}
+	r.File = FileName(r.ChannelID, r.Program, r.Start, ".ts")
+	if err := os.MkdirAll(filepath.Dir(r.Path(dir)), 0755); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if err := ioutil.WriteFile(r.Path(dir), []byte("audio"), 0644); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if err := Save(dir, r); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err := Remove(dir, r); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Dir(r.Path(dir))); !os.IsNotExist(err) {
+		t.Fatalf("got %v, want the channel directory removed", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+}
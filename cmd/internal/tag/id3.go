@@ -0,0 +1,126 @@
+package tag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ID3v2.4 spec: http://id3.org/id3v2.4.0-structure
+// and http://id3.org/id3v2-chapters-1.0
+
+const (
+	id3HeaderSize = 10
+	id3UTF8       = 3
+	id3NoOffset   = 0xFFFFFFFF
+)
+
+// EncodeID3 returns the ID3v2.4 tag of t.
+func EncodeID3(t Tags) []byte {
+	var frames bytes.Buffer
+	writeTextFrame(&frames, "TIT2", t.Title)
+	writeTextFrame(&frames, "TPE1", t.Artist)
+	writeTextFrame(&frames, "TALB", t.Album)
+	if !t.Date.IsZero() {
+		writeTextFrame(&frames, "TDRC", t.Date.Format("2006-01-02T15:04"))
+	}
+	if len(t.Image) > 0 {
+		var apic bytes.Buffer
+		apic.WriteByte(id3UTF8)
+		apic.WriteString(t.ImageType)
+		apic.WriteByte(0)
+		apic.WriteByte(3) // front cover
+		apic.WriteByte(0) // empty description
+		apic.Write(t.Image)
+		writeFrame(&frames, "APIC", apic.Bytes())
+	}
+
+	if len(t.Chapters) > 0 {
+		var toc bytes.Buffer
+		toc.WriteString("toc\x00")
+		toc.WriteByte(0x03) // top-level and ordered
+		toc.WriteByte(byte(len(t.Chapters)))
+		for i := range t.Chapters {
+			fmt.Fprintf(&toc, "chp%d\x00", i)
+		}
+		writeFrame(&frames, "CTOC", toc.Bytes())
+
+		for i, c := range t.Chapters {
+			var chap bytes.Buffer
+			fmt.Fprintf(&chap, "chp%d\x00", i)
+			binary.Write(&chap, binary.BigEndian, []uint32{
+				uint32(c.Start.Nanoseconds() / 1e6),
+				uint32(c.End.Nanoseconds() / 1e6),
+				id3NoOffset,
+				id3NoOffset,
+			})
+			writeTextFrame(&chap, "TIT2", c.Title)
+			writeFrame(&frames, "CHAP", chap.Bytes())
+		}
+	}
+
+	var tag bytes.Buffer
+	tag.WriteString("ID3\x04\x00\x00")
+	tag.Write(synchsafe(frames.Len()))
+	tag.Write(frames.Bytes())
+	return tag.Bytes()
+}
+
+func writeTextFrame(w *bytes.Buffer, id, text string) {
+	if text == "" {
+		return
+	}
+	writeFrame(w, id, append([]byte{id3UTF8}, text...))
+}
+
+func writeFrame(w *bytes.Buffer, id string, data []byte) {
+	w.WriteString(id)
+	w.Write(synchsafe(len(data)))
+	w.Write([]byte{0, 0}) // flags
+	w.Write(data)
+}
+
+// synchsafe encodes n in 4 bytes with the most significant bits zeroed.
+func synchsafe(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+}
+
+// id3Size returns the size of the ID3v2 tag starting with the header, or 0 if
+// there is no tag.
+func id3Size(header []byte) int64 {
+	if len(header) < id3HeaderSize || string(header[:3]) != "ID3" {
+		return 0
+	}
+	var size int64
+	for _, b := range header[6:10] {
+		size = size<<7 | int64(b&0x7F)
+	}
+	size += id3HeaderSize
+	if header[5]&0x10 != 0 {
+		// footer present
+		size += id3HeaderSize
+	}
+	return size
+}
+
+// writeID3File prepends the ID3v2 tag of t to the file, replacing the existing
+// tag.
+func writeID3File(name string, t Tags) error {
+	return rewrite(name, func(w io.Writer, r *os.File) error {
+		header := make([]byte, id3HeaderSize)
+		n, err := io.ReadFull(r, header)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return err
+		}
+		if _, err := r.Seek(id3Size(header[:n]), 0); err != nil {
+			return err
+		}
+		if _, err := w.Write(EncodeID3(t)); err != nil {
+			return err
+		}
+		_, err = io.Copy(w, r)
+		return err
+	})
+}
@@ -0,0 +1,116 @@
+package tag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testTags = Tags{
+	Title:     "週日 HIT DJ",
+	Artist:    "HitFm聯播網 Taipei 北部",
+	Album:     "週日 HIT DJ",
+	Date:      time.Date(2015, 3, 1, 17, 0, 0, 0, time.FixedZone("CST", 8*3600)),
+	Image:     []byte("\x89PNG"),
+	ImageType: "image/png",
+	Chapters: []Chapter{
+		{Title: "週日 HIT DJ", Start: 0, End: 30 * time.Minute},
+		{Title: "HITO唱片行", Start: 30 * time.Minute, End: 90 * time.Minute},
+	},
+}
+
+// parseFrames returns the frames of ID3v2.4 data by frame ID.
+func parseFrames(t *testing.T, data []byte) map[string][][]byte {
+	frames := make(map[string][][]byte)
+	for len(data) >= 10 {
+		id := string(data[:4])
+		size := int(id3Size(append([]byte("ID3\x04\x00\x00"), data[4:8]...)) - id3HeaderSize)
+		if size > len(data)-10 {
+			t.Fatalf("frame %s of size %d exceeds data", id, size)
+		}
+		frames[id] = append(frames[id], data[10:10+size])
+		data = data[10+size:]
+	}
+	return frames
+}
+
+func TestEncodeID3(t *testing.T) {
+	tag := EncodeID3(testTags)
+	if string(tag[:5]) != "ID3\x04\x00" {
+		t.Fatalf("got header %q, want ID3v2.4", tag[:5])
+	}
+	if got := id3Size(tag); got != int64(len(tag)) {
+		t.Fatalf("got size %d, want %d", got, len(tag))
+	}
+
+	frames := parseFrames(t, tag[id3HeaderSize:])
+	for id, want := range map[string]string{
+		"TIT2": "\x03週日 HIT DJ",
+		"TPE1": "\x03HitFm聯播網 Taipei 北部",
+		"TDRC": "\x032015-03-01T17:00",
+		"APIC": "\x03image/png\x00\x03\x00\x89PNG",
+		"CTOC": "toc\x00\x03\x02chp0\x00chp1\x00",
+	} {
+		if len(frames[id]) != 1 || string(frames[id][0]) != want {
+			t.Errorf("got %s %q, want %q", id, frames[id], want)
+		}
+	}
+
+	chapters := frames["CHAP"]
+	if len(chapters) != 2 {
+		t.Fatalf("got %d chapters, want %d", len(chapters), 2)
+	}
+	chap := chapters[1]
+	if !bytes.HasPrefix(chap, []byte("chp1\x00")) {
+		t.Fatalf("got chapter %q, want chp1", chap)
+	}
+	var times [4]uint32
+	binary.Read(bytes.NewReader(chap[5:21]), binary.BigEndian, &times)
+	if times != [4]uint32{1800000, 5400000, id3NoOffset, id3NoOffset} {
+		t.Fatalf("got chapter times %v", times)
+	}
+	sub := parseFrames(t, chap[21:])
+	if title := string(sub["TIT2"][0]); title != "\x03HITO唱片行" {
+		t.Fatalf("got chapter title %q, want %q", title, "\x03HITO唱片行")
+	}
+}
+
+func TestWriteID3File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tag")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "recording.aac")
+	audio := []byte("\xff\xf1audio")
+	if err := ioutil.WriteFile(name, audio, 0644); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	// tagging twice replaces the tag
+	for _, title := range []string{"first", "second"} {
+		tags := testTags
+		tags.Title = title
+		if err := WriteFile(name, tags); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	size := id3Size(data)
+	if !bytes.Equal(data[size:], audio) {
+		t.Fatalf("got audio %q, want %q", data[size:], audio)
+	}
+	frames := parseFrames(t, data[id3HeaderSize:size])
+	if got := string(frames["TIT2"][0]); got != "\x03second" {
+		t.Fatalf("got title %q, want %q", got, "\x03second")
+	}
+}
@@ -0,0 +1,277 @@
+package tag
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// MP4 spec: ISO/IEC 14496-12, iTunes metadata:
+// https://developer.apple.com/library/archive/documentation/QuickTime/QTFF/Metadata/Metadata.html
+
+// ErrInvalidMP4 is returned when the file is not a valid MP4 file.
+var ErrInvalidMP4 = errors.New("invalid MP4 file")
+
+const (
+	mp4TypeUTF8 = 1
+	mp4TypeJPEG = 13
+	mp4TypePNG  = 14
+)
+
+// box represents an MP4 box in the file, Size includes the header.
+type box struct {
+	Type       string
+	Offset     int64
+	Size       int64
+	HeaderSize int64
+}
+
+// readBoxes returns the top-level boxes of the file.
+func readBoxes(r io.ReaderAt, size int64) ([]box, error) {
+	var boxes []box
+	header := make([]byte, 16)
+	for off := int64(0); off < size; {
+		if _, err := r.ReadAt(header[:8], off); err != nil {
+			return nil, ErrInvalidMP4
+		}
+		b := box{Type: string(header[4:8]), Offset: off, Size: int64(binary.BigEndian.Uint32(header)), HeaderSize: 8}
+		switch b.Size {
+		case 0:
+			b.Size = size - off
+		case 1:
+			if _, err := r.ReadAt(header[8:16], off+8); err != nil {
+				return nil, ErrInvalidMP4
+			}
+			b.Size = int64(binary.BigEndian.Uint64(header[8:16]))
+			b.HeaderSize = 16
+		}
+		if b.Size < b.HeaderSize || off+b.Size > size {
+			return nil, ErrInvalidMP4
+		}
+		boxes = append(boxes, b)
+		off += b.Size
+	}
+	return boxes, nil
+}
+
+// child represents a box parsed in memory, Data excludes the header.
+type child struct {
+	Type string
+	Data []byte
+}
+
+func parseChildren(data []byte) ([]child, error) {
+	var children []child
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, ErrInvalidMP4
+		}
+		size := int(binary.BigEndian.Uint32(data))
+		if size < 8 || size > len(data) {
+			return nil, ErrInvalidMP4
+		}
+		children = append(children, child{string(data[4:8]), data[8:size]})
+		data = data[size:]
+	}
+	return children, nil
+}
+
+func appendBox(dst []byte, typ string, payloads ...[]byte) []byte {
+	size := 8
+	for _, p := range payloads {
+		size += len(p)
+	}
+	dst = append(dst, byte(size>>24), byte(size>>16), byte(size>>8), byte(size))
+	dst = append(dst, typ...)
+	for _, p := range payloads {
+		dst = append(dst, p...)
+	}
+	return dst
+}
+
+func appendChildren(dst []byte, children []child) []byte {
+	for _, c := range children {
+		dst = appendBox(dst, c.Type, c.Data)
+	}
+	return dst
+}
+
+// encodeUdta returns the payload of the metadata and Nero chapters boxes of
+// t, to be placed in moov/udta.
+func encodeUdta(t Tags) []byte {
+	var ilst []byte
+	item := func(typ string, dataType uint32, value []byte) {
+		data := make([]byte, 8, 8+len(value))
+		binary.BigEndian.PutUint32(data, dataType)
+		ilst = appendBox(ilst, typ, appendBox(nil, "data", append(data, value...)))
+	}
+	text := func(typ, value string) {
+		if value != "" {
+			item(typ, mp4TypeUTF8, []byte(value))
+		}
+	}
+	text("\xa9nam", t.Title)
+	text("\xa9ART", t.Artist)
+	text("\xa9alb", t.Album)
+	if !t.Date.IsZero() {
+		text("\xa9day", t.Date.Format("2006-01-02T15:04:05Z07:00"))
+	}
+	if len(t.Image) > 0 {
+		imageType := uint32(mp4TypePNG)
+		if t.ImageType == "image/jpeg" {
+			imageType = mp4TypeJPEG
+		}
+		item("covr", imageType, t.Image)
+	}
+
+	hdlr := make([]byte, 25)
+	copy(hdlr[8:], "mdirappl")
+	meta := appendBox(make([]byte, 4), "hdlr", hdlr)
+	meta = appendBox(meta, "ilst", ilst)
+	udta := appendBox(nil, "meta", meta)
+
+	if len(t.Chapters) > 0 {
+		chpl := []byte{1, 0, 0, 0, 0, 0, 0, 0, byte(len(t.Chapters))}
+		for _, c := range t.Chapters {
+			var start [8]byte
+			binary.BigEndian.PutUint64(start[:], uint64(c.Start/100)) // 100ns units
+			title := c.Title
+			if len(title) > 255 {
+				title = title[:255]
+			}
+			chpl = append(chpl, start[:]...)
+			chpl = append(chpl, byte(len(title)))
+			chpl = append(chpl, title...)
+		}
+		udta = appendBox(udta, "chpl", chpl)
+	}
+	return udta
+}
+
+// updateMoov returns the moov payload with the metadata of udta, existing
+// metadata and chapters are replaced.
+func updateMoov(moov, udta []byte) ([]byte, error) {
+	children, err := parseChildren(moov)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	for _, c := range children {
+		if c.Type != "udta" {
+			out = appendBox(out, c.Type, c.Data)
+			continue
+		}
+		items, err := parseChildren(c.Data)
+		if err != nil {
+			return nil, err
+		}
+		var kept []child
+		for _, item := range items {
+			if item.Type != "meta" && item.Type != "chpl" {
+				kept = append(kept, item)
+			}
+		}
+		udta = append(appendChildren(nil, kept), udta...)
+	}
+	return appendBox(out, "udta", udta), nil
+}
+
+// shiftChunkOffsets adds delta to the chunk offsets in the moov payload.
+func shiftChunkOffsets(data []byte, delta int64) error {
+	children, err := parseChildren(data)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		switch c.Type {
+		case "trak", "mdia", "minf", "stbl":
+			if err := shiftChunkOffsets(c.Data, delta); err != nil {
+				return err
+			}
+		case "stco", "co64":
+			width := 4
+			if c.Type == "co64" {
+				width = 8
+			}
+			if len(c.Data) < 8 {
+				return ErrInvalidMP4
+			}
+			n := int(binary.BigEndian.Uint32(c.Data[4:]))
+			entries := c.Data[8:]
+			if len(entries) < n*width {
+				return ErrInvalidMP4
+			}
+			for i := 0; i < n; i++ {
+				p := entries[i*width:]
+				if width == 4 {
+					off := int64(binary.BigEndian.Uint32(p)) + delta
+					if off < 0 || off > math.MaxUint32 {
+						return errors.New("chunk offset overflow")
+					}
+					binary.BigEndian.PutUint32(p, uint32(off))
+				} else {
+					binary.BigEndian.PutUint64(p, uint64(int64(binary.BigEndian.Uint64(p))+delta))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeMP4File writes the tags of t into moov/udta of the MP4 file, chunk
+// offsets are shifted if the media data follows the movie box.
+func writeMP4File(name string, t Tags) error {
+	return rewrite(name, func(w io.Writer, r *os.File) error {
+		info, err := r.Stat()
+		if err != nil {
+			return err
+		}
+		boxes, err := readBoxes(r, info.Size())
+		if err != nil {
+			return err
+		}
+
+		moovIndex, mdatIndex := -1, -1
+		for i, b := range boxes {
+			if b.Type == "moov" && moovIndex == -1 {
+				moovIndex = i
+			}
+			if b.Type == "mdat" && mdatIndex == -1 {
+				mdatIndex = i
+			}
+		}
+		if moovIndex == -1 {
+			return ErrInvalidMP4
+		}
+		moovBox := boxes[moovIndex]
+		data := make([]byte, moovBox.Size-moovBox.HeaderSize)
+		if _, err := r.ReadAt(data, moovBox.Offset+moovBox.HeaderSize); err != nil {
+			return err
+		}
+		moov, err := updateMoov(data, encodeUdta(t))
+		if err != nil {
+			return err
+		}
+		if mdatIndex > moovIndex {
+			delta := int64(len(moov)) + 8 - moovBox.Size
+			if err := shiftChunkOffsets(moov, delta); err != nil {
+				return err
+			}
+		}
+
+		for i, b := range boxes {
+			if i == moovIndex {
+				if _, err := w.Write(appendBox(nil, "moov", moov)); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := io.Copy(w, io.NewSectionReader(r, b.Offset, b.Size)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
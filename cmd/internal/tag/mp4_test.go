@@ -0,0 +1,126 @@
+package tag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testMP4 returns a minimal MP4 file with a chunk offset pointing to the
+// media data.
+func testMP4(moovFirst bool) []byte {
+	ftyp := appendBox(nil, "ftyp", []byte("M4A \x00\x00\x00\x00"))
+	mdat := appendBox(nil, "mdat", []byte("audio"))
+	moov := func(offset uint32) []byte {
+		stco := make([]byte, 12)
+		binary.BigEndian.PutUint32(stco[4:], 1)
+		binary.BigEndian.PutUint32(stco[8:], offset)
+		stbl := appendBox(nil, "stbl", appendBox(nil, "stco", stco))
+		minf := appendBox(nil, "minf", stbl)
+		mdia := appendBox(nil, "mdia", minf)
+		trak := appendBox(nil, "trak", mdia)
+		return appendBox(nil, "moov", appendBox(nil, "mvhd", make([]byte, 100)), trak)
+	}
+
+	if moovFirst {
+		size := len(moov(0))
+		return append(append(ftyp, moov(uint32(len(ftyp)+size+8))...), mdat...)
+	}
+	return append(append(ftyp, mdat...), moov(uint32(len(ftyp)+8))...)
+}
+
+// findBox returns the payload of the box in the path of types.
+func findBox(data []byte, types ...string) []byte {
+	children, err := parseChildren(data)
+	if err != nil {
+		return nil
+	}
+	for _, c := range children {
+		if c.Type != types[0] {
+			continue
+		}
+		if len(types) == 1 {
+			return c.Data
+		}
+		if c.Type == "meta" {
+			c.Data = c.Data[4:]
+		}
+		return findBox(c.Data, types[1:]...)
+	}
+	return nil
+}
+
+func TestWriteMP4File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tag")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, moovFirst := range []bool{true, false} {
+		name := filepath.Join(dir, "recording.m4a")
+		if err := ioutil.WriteFile(name, testMP4(moovFirst), 0644); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+
+		// tagging twice replaces the tags
+		var sizes []int
+		for i := 0; i < 2; i++ {
+			if err := WriteFile(name, testTags); err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			info, err := os.Stat(name)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			sizes = append(sizes, int(info.Size()))
+		}
+		if sizes[0] != sizes[1] {
+			t.Fatalf("got sizes %v, want the same size", sizes)
+		}
+
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		stco := findBox(data, "moov", "trak", "mdia", "minf", "stbl", "stco")
+		if offset := binary.BigEndian.Uint32(stco[8:]); string(data[offset:offset+5]) != "audio" {
+			t.Fatalf("moovFirst=%v: chunk offset %d points to %q", moovFirst, offset, data[offset:offset+5])
+		}
+
+		title := findBox(data, "moov", "udta", "meta", "ilst", "\xa9nam", "data")
+		if want := "\x00\x00\x00\x01\x00\x00\x00\x00週日 HIT DJ"; string(title) != want {
+			t.Fatalf("got title %q, want %q", title, want)
+		}
+		if covr := findBox(data, "moov", "udta", "meta", "ilst", "covr", "data"); !bytes.HasSuffix(covr, testTags.Image) {
+			t.Fatalf("got cover %q, want %q", covr, testTags.Image)
+		}
+
+		chpl := findBox(data, "moov", "udta", "chpl")
+		if len(chpl) < 9 || chpl[8] != 2 {
+			t.Fatalf("got chapters %q, want 2 chapters", chpl)
+		}
+		if start := binary.BigEndian.Uint64(chpl[9+8+1+len("週日 HIT DJ"):]); start != 18000000000 {
+			t.Fatalf("got chapter start %d, want %d", start, uint64(18000000000))
+		}
+	}
+}
+
+func TestWriteMP4FileInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tag")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "recording.mp4")
+	if err := ioutil.WriteFile(name, []byte("not an mp4 file"), 0644); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if err := WriteFile(name, testTags); err != ErrInvalidMP4 {
+		t.Fatalf("got %v, want %v", err, ErrInvalidMP4)
+	}
+}
@@ -0,0 +1,77 @@
+// Package tag writes metadata and chapters of recorded audio files, as ID3v2
+// tags for MPEG audio, ADTS AAC and MPEG-TS files or as iTunes metadata atoms
+// for MP4 files.
+package tag
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Tags represents the metadata of a recording.
+type Tags struct {
+	Title  string // program name
+	Artist string // channel title
+	Album  string
+	Date   time.Time // air date
+
+	// Image is the station logo, ImageType is its content type, e.g.
+	// image/png.
+	Image     []byte
+	ImageType string
+
+	Chapters []Chapter
+}
+
+// Chapter represents a program in the recording, Start and End are offsets
+// from the beginning of the recording.
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration
+}
+
+// WriteFile writes the tags into the file, existing tags are replaced. The
+// format of tags is chosen by the extension of the file.
+func WriteFile(name string, t Tags) error {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".m4a", ".m4b", ".mp4":
+		return writeMP4File(name, t)
+	default:
+		return writeID3File(name, t)
+	}
+}
+
+// rewrite replaces the file with the output of write atomically.
+func rewrite(name string, write func(w io.Writer, r *os.File) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".tag")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp, f); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
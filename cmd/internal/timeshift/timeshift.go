@@ -0,0 +1,302 @@
+// Package timeshift buffers a live stream on disk to pause and rewind it.
+package timeshift
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+)
+
+// DefaultWindow is the number of segments in the playlist served to players.
+const DefaultWindow = 3
+
+// Buffer is a disk-backed ring buffer holding segments of the last Length of
+// a stream.
+type Buffer struct {
+	dir    string
+	length time.Duration
+
+	mu       sync.Mutex
+	segments []entry
+}
+
+type entry struct {
+	hls.Segment
+	Time time.Time // time of arrival
+	path string
+}
+
+// NewBuffer returns a buffer storing segments in dir.
+func NewBuffer(dir string, length time.Duration) (*Buffer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Buffer{dir: dir, length: length}, nil
+}
+
+// Add stores the segment arrived at t and evicts the oldest segments which
+// exceed the buffer length.
+func (b *Buffer) Add(seg hls.Segment, t time.Time, data []byte) error {
+	name := filepath.Join(b.dir, fmt.Sprintf("%d%s", seg.Sequence, path.Ext(seg.URI)))
+	if err := ioutil.WriteFile(name, data, 0644); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.segments = append(b.segments, entry{seg, t, name})
+	for len(b.segments) > 1 && b.duration()-b.segments[0].Duration >= b.length {
+		os.Remove(b.segments[0].path)
+		b.segments = b.segments[1:]
+	}
+	return nil
+}
+
+func (b *Buffer) duration() time.Duration {
+	var d time.Duration
+	for _, e := range b.segments {
+		d += e.Duration
+	}
+	return d
+}
+
+// Duration returns the duration of buffered segments.
+func (b *Buffer) Duration() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.duration()
+}
+
+// Len returns the number of buffered segments.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.segments)
+}
+
+// Open opens the segment of the media sequence number.
+func (b *Buffer) Open(sequence int) (*os.File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range b.segments {
+		if e.Sequence == sequence {
+			return os.Open(e.path)
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+func (b *Buffer) entries() []entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]entry(nil), b.segments...)
+}
+
+// Close removes the buffered segments.
+func (b *Buffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.segments = nil
+	return os.RemoveAll(b.dir)
+}
+
+// Status represents the playback state of a timeshift.
+type Status struct {
+	Paused   bool          `json:"paused"`
+	Delay    time.Duration `json:"delay"`
+	Buffered time.Duration `json:"buffered"`
+}
+
+// Timeshift serves a buffered stream behind the live edge. The segments
+// served to the player are renumbered, so the media sequence number keeps
+// increasing across seeks.
+type Timeshift struct {
+	buf    *Buffer
+	window int
+
+	mu       sync.Mutex
+	delay    time.Duration
+	paused   bool
+	pausedAt time.Time
+	started  bool
+	next     int  // media sequence number of the next segment to serve
+	jump     bool // the next segment is discontinuous
+	served   []hls.Segment
+	out      int // output sequence number of the last served segment
+	disc     int // discontinuity sequence number
+}
+
+// New returns a timeshift of buf serving window segments in the playlist.
+func New(buf *Buffer, window int) *Timeshift {
+	return &Timeshift{buf: buf, window: window}
+}
+
+// Pause stops serving new segments.
+func (t *Timeshift) Pause(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.advance(now)
+	if !t.paused {
+		t.paused = true
+		t.pausedAt = now
+	}
+}
+
+// Resume continues serving segments from where it was paused.
+func (t *Timeshift) Resume(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.paused {
+		t.delay += now.Sub(t.pausedAt)
+		t.paused = false
+	}
+	t.clamp(now)
+}
+
+// Seek moves the playback position by d, e.g. -5m rewinds 5 minutes. The
+// position is bounded by the buffer and the live edge.
+func (t *Timeshift) Seek(now time.Time, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.paused {
+		t.delay += now.Sub(t.pausedAt)
+		t.pausedAt = now
+	}
+	t.delay -= d
+	t.clamp(now)
+	t.seek(now)
+}
+
+// Live moves the playback position to the live edge.
+func (t *Timeshift) Live(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.delay = 0
+	t.paused = false
+	t.seek(now)
+}
+
+// Status returns the playback state.
+func (t *Timeshift) Status(now time.Time) Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delay := t.delay
+	if t.paused {
+		delay += now.Sub(t.pausedAt)
+	}
+	return Status{Paused: t.paused, Delay: delay, Buffered: t.buf.Duration()}
+}
+
+// clamp bounds the delay by the buffered duration.
+func (t *Timeshift) clamp(now time.Time) {
+	if t.delay < 0 {
+		t.delay = 0
+	}
+	entries := t.buf.entries()
+	if len(entries) == 0 {
+		return
+	}
+	if max := now.Sub(entries[0].Time); t.delay > max {
+		t.delay = max
+	}
+}
+
+// seek sets the next segment to the latest one arrived before the position.
+func (t *Timeshift) seek(now time.Time) {
+	entries := t.buf.entries()
+	if len(entries) == 0 {
+		return
+	}
+	target := now.Add(-t.delay)
+	next := entries[0].Sequence
+	for _, e := range entries {
+		if e.Time.After(target) {
+			break
+		}
+		next = e.Sequence
+	}
+	t.next = next
+	t.jump = t.started
+	t.started = true
+}
+
+// advance serves the segments arrived before the playback position.
+func (t *Timeshift) advance(now time.Time) {
+	if t.paused {
+		return
+	}
+	entries := t.buf.entries()
+	if len(entries) == 0 {
+		return
+	}
+	if !t.started {
+		// start with a full window
+		i := len(entries) - t.window
+		if i < 0 {
+			i = 0
+		}
+		t.next = entries[i].Sequence
+		t.started = true
+	}
+	if t.next < entries[0].Sequence {
+		// the position is evicted from the buffer
+		t.next = entries[0].Sequence
+		t.jump = true
+	}
+
+	target := now.Add(-t.delay)
+	for _, e := range entries {
+		if e.Sequence < t.next {
+			continue
+		}
+		if e.Time.After(target) && len(t.served) > 0 {
+			break
+		}
+		seg := e.Segment
+		seg.Discontinuity = t.jump && len(t.served) > 0
+		t.jump = false
+		t.out++
+		seg.URI = fmt.Sprintf("%d%s", e.Sequence, path.Ext(e.URI))
+		seg.Title = ""
+		t.served = append(t.served, seg)
+		t.next = e.Sequence + 1
+	}
+	for len(t.served) > t.window {
+		if t.served[0].Discontinuity {
+			t.disc++
+		}
+		t.served = t.served[1:]
+	}
+}
+
+// Playlist returns the live playlist for the player, segment URIs are the
+// media sequence number with the extension of the original URI, relative to
+// the playlist.
+func (t *Timeshift) Playlist(now time.Time) *hls.Playlist {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.advance(now)
+
+	p := &hls.Playlist{
+		MediaSequence:         t.out - len(t.served) + 1,
+		DiscontinuitySequence: t.disc,
+		Segments:              append([]hls.Segment(nil), t.served...),
+	}
+	for _, s := range t.served {
+		if s.Duration > p.TargetDuration {
+			p.TargetDuration = s.Duration
+		}
+	}
+	p.TargetDuration = time.Duration(math.Ceil(p.TargetDuration.Seconds())) * time.Second
+	for i := range p.Segments {
+		p.Segments[i].Sequence = p.MediaSequence + i
+	}
+	return p
+}
@@ -0,0 +1,161 @@
+package timeshift
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parkghost/hiradio/cmd/internal/hls"
+)
+
+var t0 = time.Date(2015, 3, 1, 17, 0, 0, 0, time.UTC)
+
+func newTestBuffer(t *testing.T, length time.Duration) *Buffer {
+	dir, err := ioutil.TempDir("", "timeshift")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	buf, err := NewBuffer(dir, length)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	return buf
+}
+
+// addSegment adds the 10 seconds segment seq arrived at t0+seq*10s.
+func addSegment(t *testing.T, buf *Buffer, seq int) {
+	seg := hls.Segment{URI: fmt.Sprintf("media_%d.ts", seq), Duration: 10 * time.Second, Sequence: seq}
+	if err := buf.Add(seg, at(seq*10), []byte(fmt.Sprint(seq))); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+}
+
+func at(sec int) time.Time {
+	return t0.Add(time.Duration(sec) * time.Second)
+}
+
+func uris(p *hls.Playlist) []string {
+	var list []string
+	for _, s := range p.Segments {
+		uri := s.URI
+		if s.Discontinuity {
+			uri = "|" + uri
+		}
+		list = append(list, uri)
+	}
+	return list
+}
+
+func TestBuffer(t *testing.T) {
+	buf := newTestBuffer(t, 60*time.Second)
+	defer buf.Close()
+	for i := 0; i < 10; i++ {
+		addSegment(t, buf, i)
+	}
+
+	if got, want := buf.Len(), 6; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := buf.Duration(), 60*time.Second; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if _, err := buf.Open(3); !os.IsNotExist(err) {
+		t.Fatalf("got %v, want %v", err, os.ErrNotExist)
+	}
+	f, err := buf.Open(9)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	data, _ := ioutil.ReadAll(f)
+	f.Close()
+	if string(data) != "9" {
+		t.Fatalf("got %q, want %q", data, "9")
+	}
+
+	if err := buf.Close(); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if _, err := os.Stat(buf.dir); !os.IsNotExist(err) {
+		t.Fatalf("expected the buffer directory removed")
+	}
+}
+
+func TestTimeshift(t *testing.T) {
+	buf := newTestBuffer(t, 60*time.Second)
+	defer buf.Close()
+	for i := 0; i < 10; i++ {
+		addSegment(t, buf, i)
+	}
+	ts := New(buf, 3)
+
+	// live
+	p := ts.Playlist(at(90))
+	if got, want := uris(p), []string{"7.ts", "8.ts", "9.ts"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if p.MediaSequence != 1 || p.TargetDuration != 10*time.Second {
+		t.Fatalf("got sequence %d, target duration %v", p.MediaSequence, p.TargetDuration)
+	}
+
+	// paused playlist is frozen
+	ts.Pause(at(95))
+	addSegment(t, buf, 10)
+	if got, want := uris(ts.Playlist(at(200))), []string{"7.ts", "8.ts", "9.ts"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// resume from where it was paused
+	ts.Resume(at(200))
+	if got, want := ts.Status(at(200)).Delay, 105*time.Second; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := uris(ts.Playlist(at(200))), []string{"7.ts", "8.ts", "9.ts"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	p = ts.Playlist(at(205))
+	if got, want := uris(p), []string{"8.ts", "9.ts", "10.ts"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if p.MediaSequence != 2 {
+		t.Fatalf("got %d, want %d", p.MediaSequence, 2)
+	}
+
+	// rewind
+	ts.Seek(at(205), -30*time.Second)
+	p = ts.Playlist(at(205))
+	if got, want := uris(p), []string{"9.ts", "10.ts", "|7.ts"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// back to live
+	ts.Live(at(210))
+	p = ts.Playlist(at(210))
+	if got, want := uris(p), []string{"10.ts", "|7.ts", "|10.ts"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := ts.Status(at(210)); got.Paused || got.Delay != 0 {
+		t.Fatalf("got %+v, want live", got)
+	}
+}
+
+func TestTimeshiftSeekBounds(t *testing.T) {
+	buf := newTestBuffer(t, 60*time.Second)
+	defer buf.Close()
+	for i := 0; i < 10; i++ {
+		addSegment(t, buf, i)
+	}
+	ts := New(buf, 3)
+	ts.Playlist(at(90))
+
+	ts.Seek(at(90), -1*time.Hour)
+	if got, want := ts.Status(at(90)).Delay, 50*time.Second; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	ts.Seek(at(90), 1*time.Hour)
+	if got := ts.Status(at(90)).Delay; got != 0 {
+		t.Fatalf("got %v, want %v", got, 0)
+	}
+}
@@ -0,0 +1,100 @@
+package hiradio
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket limiting the rate of API requests. It is
+// safe for concurrent use and can be shared by clients.
+type RateLimiter struct {
+	rate  float64
+	burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	// until is the end of a pause requested by the server.
+	until time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing rate requests per second on
+// average and bursts of at most burst requests. A rate <= 0 is unlimited, only
+// pauses requested by the server are applied.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{rate: rate, burst: burst, tokens: float64(burst)}
+}
+
+// Wait blocks until a request is allowed.
+func (l *RateLimiter) Wait() {
+	if d := l.reserve(time.Now()); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// reserve takes a token at now and returns the delay until the request is
+// allowed.
+func (l *RateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var delay time.Duration
+	if l.rate > 0 {
+		if !l.last.IsZero() && now.After(l.last) {
+			l.tokens += now.Sub(l.last).Seconds() * l.rate
+			if l.tokens > float64(l.burst) {
+				l.tokens = float64(l.burst)
+			}
+		}
+		if now.After(l.last) {
+			l.last = now
+		}
+		l.tokens--
+		if l.tokens < 0 {
+			delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+		}
+	}
+	if d := l.until.Sub(now); d > delay {
+		delay = d
+	}
+	return delay
+}
+
+// pause holds all requests until t.
+func (l *RateLimiter) pause(t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.After(l.until) {
+		l.until = t
+	}
+}
+
+// retryAfter returns the delay requested by a 429 or 503 response with a
+// Retry-After header in seconds or an HTTP date.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
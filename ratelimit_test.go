@@ -0,0 +1,89 @@
+package hiradio
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	start := time.Date(2016, 5, 1, 8, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(2, 2)
+
+	tests := []struct {
+		at   time.Duration
+		want time.Duration
+	}{
+		// burst
+		{0, 0},
+		{0, 0},
+		// then 2 requests per second
+		{0, 500 * time.Millisecond},
+		{0, 1 * time.Second},
+		{1 * time.Second, 500 * time.Millisecond},
+		// refilled up to the burst
+		{10 * time.Second, 0},
+		{10 * time.Second, 0},
+		{10 * time.Second, 500 * time.Millisecond},
+	}
+	for i, test := range tests {
+		if got := l.reserve(start.Add(test.at)); got != test.want {
+			t.Fatalf("%d: got %s, want %s", i, got, test.want)
+		}
+	}
+
+	// paused by the server
+	l = NewRateLimiter(2, 2)
+	l.pause(start.Add(30 * time.Second))
+	if got, want := l.reserve(start), 30*time.Second; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := l.reserve(start.Add(40*time.Second)), time.Duration(0); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	start := time.Date(2016, 5, 1, 8, 0, 0, 0, time.UTC)
+	for _, rate := range []float64{0, -1} {
+		l := NewRateLimiter(rate, 1)
+		for i := 0; i < 3; i++ {
+			if got := l.reserve(start); got != 0 {
+				t.Fatalf("rate %v: got %s, want 0", rate, got)
+			}
+		}
+		// pauses are still applied
+		l.pause(start.Add(30 * time.Second))
+		if got, want := l.reserve(start), 30*time.Second; got != want {
+			t.Fatalf("rate %v: got %s, want %s", rate, got, want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2016, 5, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status     int
+		retryAfter string
+		want       time.Duration
+		found      bool
+	}{
+		{http.StatusTooManyRequests, "120", 2 * time.Minute, true},
+		{http.StatusServiceUnavailable, "Sun, 01 May 2016 08:00:30 GMT", 30 * time.Second, true},
+		{http.StatusServiceUnavailable, "Sun, 01 May 2016 07:59:00 GMT", 0, true},
+		{http.StatusServiceUnavailable, "", 0, false},
+		{http.StatusTooManyRequests, "soon", 0, false},
+		{http.StatusTooManyRequests, "-1", 0, false},
+		{http.StatusInternalServerError, "120", 0, false},
+	}
+	for _, test := range tests {
+		resp := &http.Response{StatusCode: test.status, Header: http.Header{}}
+		if test.retryAfter != "" {
+			resp.Header.Set("Retry-After", test.retryAfter)
+		}
+		got, found := retryAfter(resp, now)
+		if got != test.want || found != test.found {
+			t.Errorf("%d %q: got %s %v, want %s %v", test.status, test.retryAfter, got, found, test.want, test.found)
+		}
+	}
+}